@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 查询用户列表
+func AdminUserList(ctx iris.Context) {
+	ctx.JSON(common.NewSuccessData("查询成功", service.AdminUserList()))
+}
+
+// 添加用户
+func AdminUserAdd(ctx iris.Context) {
+	user := entity.User{}
+	resolveParam(ctx, &user)
+	ctx.JSON(common.NewSuccessData("添加成功", service.AdminUserAdd(user)))
+}
+
+// 修改用户角色
+func AdminUserUpdateRole(ctx iris.Context) {
+	userCondition := entity.UserCondition{}
+	resolveParam(ctx, &userCondition)
+	service.AdminUserUpdateRole(userCondition, middleware.CurrentUserId(ctx))
+	ctx.JSON(common.NewSuccess("更新成功"))
+}
+
+// 禁用用户
+func AdminUserDisable(ctx iris.Context) {
+	userCondition := entity.UserCondition{}
+	resolveParam(ctx, &userCondition)
+	service.AdminUserUpdateDisabled(userCondition.Id, true, middleware.CurrentUserId(ctx))
+	ctx.JSON(common.NewSuccess("禁用成功"))
+}
+
+// 启用用户
+func AdminUserEnable(ctx iris.Context) {
+	userCondition := entity.UserCondition{}
+	resolveParam(ctx, &userCondition)
+	service.AdminUserUpdateDisabled(userCondition.Id, false, middleware.CurrentUserId(ctx))
+	ctx.JSON(common.NewSuccess("启用成功"))
+}
+
+// 强制重置用户密码
+func AdminUserResetPassword(ctx iris.Context) {
+	userCondition := entity.UserCondition{}
+	resolveParam(ctx, &userCondition)
+	service.AdminUserResetPassword(userCondition)
+	ctx.JSON(common.NewSuccess("重置成功"))
+}
+
+// 重置用户的两步验证
+func AdminUserResetTotp(ctx iris.Context) {
+	userCondition := entity.UserCondition{}
+	resolveParam(ctx, &userCondition)
+	service.AdminUserResetTotp(userCondition.Id)
+	ctx.JSON(common.NewSuccess("重置成功"))
+}
+
+// 删除用户
+func AdminUserDelete(ctx iris.Context) {
+	userCondition := entity.UserCondition{}
+	resolveParam(ctx, &userCondition)
+	service.AdminUserDelete(userCondition.Id, middleware.CurrentUserId(ctx))
+	ctx.JSON(common.NewSuccess("删除成功"))
+}
+
+// 查询锁定中的用户名和ip
+func AdminSignInLockList(ctx iris.Context) {
+	ctx.JSON(common.NewSuccessData("查询成功", service.SignInLockList()))
+}
+
+// 解除用户名或ip的登录锁定
+func AdminSignInUnlock(ctx iris.Context) {
+	condition := entity.SignInAttemptCondition{}
+	resolveParam(ctx, &condition)
+	service.SignInUnlock(condition)
+	ctx.JSON(common.NewSuccess("解除成功"))
+}
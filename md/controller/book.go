@@ -41,3 +41,27 @@ func BookList(ctx iris.Context) {
 	userId := middleware.CurrentUserId(ctx)
 	ctx.JSON(common.NewSuccessData("查询成功", service.BookList(userId)))
 }
+
+// 查询目录树
+func BookTree(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.BookTree(userId)))
+}
+
+// 移动目录
+func BookMove(ctx iris.Context) {
+	book := entity.Book{}
+	resolveParam(ctx, &book)
+	book.UserId = middleware.CurrentUserId(ctx)
+	service.BookMove(book)
+	ctx.JSON(common.NewSuccess("移动成功"))
+}
+
+// 调整目录顺序
+func BookReorder(ctx iris.Context) {
+	condition := entity.ReorderCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.BookReorder(condition.Ids, userId)
+	ctx.JSON(common.NewSuccess("排序成功"))
+}
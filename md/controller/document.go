@@ -5,6 +5,7 @@ import (
 	"md/model/common"
 	"md/model/entity"
 	"md/service"
+	"md/util"
 
 	"github.com/kataras/iris/v12"
 )
@@ -14,6 +15,9 @@ func DocumentAdd(ctx iris.Context) {
 	document := entity.Document{}
 	resolveParam(ctx, &document)
 	document.UserId = middleware.CurrentUserId(ctx)
+	if document.Published {
+		middleware.CheckScope(ctx, common.ScopePublish)
+	}
 	ctx.JSON(common.NewSuccessData("添加成功", service.DocumentAdd(document)))
 }
 
@@ -22,6 +26,10 @@ func DocumentUpdate(ctx iris.Context) {
 	document := entity.Document{}
 	resolveParam(ctx, &document)
 	document.UserId = middleware.CurrentUserId(ctx)
+	// 修改公开发布状态需要发布权限
+	if document.Published != service.DocumentGet(document.Id, document.UserId).Published {
+		middleware.CheckScope(ctx, common.ScopePublish)
+	}
 	service.DocumentUpdate(document)
 	ctx.JSON(common.NewSuccess("更新成功"))
 }
@@ -30,6 +38,10 @@ func DocumentUpdate(ctx iris.Context) {
 func DocumentUpdateContent(ctx iris.Context) {
 	document := entity.Document{}
 	resolveParam(ctx, &document)
+	// 需传入读取文档时的版本号，避免多个页面同时编辑时互相覆盖
+	if document.ExpectedVersion <= 0 {
+		panic(common.NewError("文档版本号expectedVersion不可为空"))
+	}
 	document.UserId = middleware.CurrentUserId(ctx)
 	ctx.JSON(common.NewSuccessData("更新成功", service.DocumentUpdateContent(document)))
 }
@@ -43,12 +55,30 @@ func DocumentDelete(ctx iris.Context) {
 	ctx.JSON(common.NewSuccess("删除成功"))
 }
 
-// 查询文档列表
-func DocumentList(ctx iris.Context) {
+// 移动文档
+func DocumentMove(ctx iris.Context) {
 	document := entity.Document{}
 	resolveParam(ctx, &document)
+	document.UserId = middleware.CurrentUserId(ctx)
+	service.DocumentMove(document)
+	ctx.JSON(common.NewSuccess("移动成功"))
+}
+
+// 调整文档顺序
+func DocumentReorder(ctx iris.Context) {
+	condition := entity.ReorderCondition{}
+	resolveParam(ctx, &condition)
 	userId := middleware.CurrentUserId(ctx)
-	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentList(document.BookId, userId)))
+	service.DocumentReorder(condition.Ids, userId)
+	ctx.JSON(common.NewSuccess("排序成功"))
+}
+
+// 查询文档列表
+func DocumentList(ctx iris.Context) {
+	condition := entity.DocumentListCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentList(condition, userId)))
 }
 
 // 查询文档
@@ -65,9 +95,69 @@ func DocumentGetPublished(ctx iris.Context) {
 	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentGetPublished(id)))
 }
 
+// 查询公开发布文档渲染后的html
+func DocumentHtmlPublished(ctx iris.Context) {
+	id := ctx.Params().Get("id")
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentHtmlPublished(id)))
+}
+
 // 分页查询公开发布文档列表
 func DocumentPagePulished(ctx iris.Context) {
 	pageCondition := common.PageCondition[entity.DocumentPageCondition]{}
 	resolveParam(ctx, &pageCondition)
 	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentPagePulished(pageCondition)))
 }
+
+// 全文检索文档
+func DocumentSearch(ctx iris.Context) {
+	pageCondition := common.PageCondition[entity.DocumentSearchCondition]{}
+	resolveParam(ctx, &pageCondition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentSearch(pageCondition, userId)))
+}
+
+// 公开发布文档的RSS订阅，可按用户名、目录名过滤
+func DocumentFeedRSS(ctx iris.Context) {
+	feed := documentFeed(ctx, "/api/open/feed.rss")
+	data, err := util.FeedRSS(feed)
+	if err != nil {
+		panic(common.NewErr("生成订阅失败", err))
+	}
+	ctx.ContentType("application/rss+xml; charset=utf-8")
+	ctx.Write(data)
+}
+
+// 公开发布文档的Atom订阅，可按用户名、目录名过滤
+func DocumentFeedAtom(ctx iris.Context) {
+	feed := documentFeed(ctx, "/api/open/feed.atom")
+	data, err := util.FeedAtom(feed)
+	if err != nil {
+		panic(common.NewErr("生成订阅失败", err))
+	}
+	ctx.ContentType("application/atom+xml; charset=utf-8")
+	ctx.Write(data)
+}
+
+// 根据查询参数生成订阅源，链接使用请求的协议和域名
+func documentFeed(ctx iris.Context, feedPath string) util.Feed {
+	condition := entity.DocumentPageCondition{
+		Username: ctx.URLParam("username"),
+		BookName: ctx.URLParam("bookName"),
+	}
+
+	scheme := "http"
+	if ctx.Request().TLS != nil {
+		scheme = "https"
+	}
+	if proto := ctx.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	baseUrl := scheme + "://" + ctx.Host()
+
+	feed := service.DocumentFeed(condition, baseUrl)
+	feed.FeedLink = baseUrl + feedPath
+	if query := ctx.Request().URL.RawQuery; query != "" {
+		feed.FeedLink += "?" + query
+	}
+	return feed
+}
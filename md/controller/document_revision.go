@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 查询文档历史版本列表
+func DocumentRevisionList(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentRevisionList(condition.DocumentId, userId)))
+}
+
+// 查询文档历史版本
+func DocumentRevisionGet(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentRevisionGet(condition.Id, userId)))
+}
+
+// 比较文档历史版本差异
+func DocumentRevisionDiff(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentRevisionDiff(condition, userId)))
+}
+
+// 恢复文档历史版本
+func DocumentRevisionRestore(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("恢复成功", service.DocumentRevisionRestore(condition.Id, userId)))
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+	"strings"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 添加文档分享
+func DocumentShareAdd(ctx iris.Context) {
+	condition := entity.DocumentShareCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("分享成功", service.DocumentShareAdd(condition, userId)))
+}
+
+// 查询文档的分享列表
+func DocumentShareList(ctx iris.Context) {
+	condition := entity.DocumentShareCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentShareList(condition.DocumentId, userId)))
+}
+
+// 撤销文档分享
+func DocumentShareRevoke(ctx iris.Context) {
+	condition := entity.DocumentShareCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.DocumentShareRevoke(condition.Id, userId)
+	ctx.JSON(common.NewSuccess("撤销成功"))
+}
+
+// 删除文档分享
+func DocumentShareDelete(ctx iris.Context) {
+	condition := entity.DocumentShareCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.DocumentShareDelete(condition.Id, userId)
+	ctx.JSON(common.NewSuccess("删除成功"))
+}
+
+// 通过分享链接查看文档，没有访问密码时可直接GET，有访问密码时POST请求体（json或表单）中传入password
+func DocumentShareView(ctx iris.Context) {
+	token := ctx.Params().Get("token")
+	condition := entity.DocumentShareCondition{}
+	if ctx.Method() == iris.MethodPost && ctx.GetContentLength() > 0 {
+		if strings.HasPrefix(ctx.GetHeader("Content-Type"), "application/x-www-form-urlencoded") {
+			condition.Password = ctx.FormValue("password")
+		} else {
+			resolveParam(ctx, &condition)
+		}
+	}
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentShareView(token, condition.Password, ctx.RemoteAddr())))
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"io"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 导出目录为zip
+func BookExport(ctx iris.Context) {
+	book := entity.Book{}
+	resolveParam(ctx, &book)
+	userId := middleware.CurrentUserId(ctx)
+	book = service.BookExportCheck(book.Id, userId)
+
+	writeZip(ctx, book.Name+".zip", func(w io.Writer) error {
+		return service.BookExport(w, book, userId)
+	})
+}
+
+// 导出全部数据为zip
+func Export(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	writeZip(ctx, "export.zip", func(w io.Writer) error {
+		return service.ExportAll(w, userId)
+	})
+}
+
+// 导出全部公开发布文档为静态站点zip
+func ExportSite(ctx iris.Context) {
+	condition := entity.SiteExportCondition{}
+	resolveParam(ctx, &condition)
+	writeZip(ctx, "site.zip", func(w io.Writer) error {
+		return service.ExportSite(w, condition.Url)
+	})
+}
+
+// 备份数据库和图片为zip
+func Backup(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	service.BackupCheck(userId)
+	writeZip(ctx, "backup-"+time.Now().Format("20060102150405")+".zip", service.Backup)
+}
+
+// 以附件形式输出zip，先写入临时文件，全部写入成功后再输出，避免出错时返回不完整的zip
+func writeZip(ctx iris.Context, filename string, write func(w io.Writer) error) {
+	file, err := os.CreateTemp("", "md-*.zip")
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	err = write(file)
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+
+	ctx.ContentType("application/zip")
+	ctx.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(filename))
+	ctx.Header("Content-Length", strconv.FormatInt(size, 10))
+	if _, err = io.Copy(ctx.ResponseWriter(), file); err != nil {
+		// 已开始输出，只记录日志
+		middleware.Log.Error("导出失败：", err)
+	}
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"io"
+	"md/middleware"
+	"md/model/common"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 导入markdown文件或zip压缩包
+func Import(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	files, headers, err := ctx.FormFiles("files")
+	if err != nil {
+		panic(common.NewErr("文件解析失败", err))
+	}
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	// 按实际读取的字节数限制上传文件的总大小
+	importFiles := []service.ImportFile{}
+	var size int64
+	for i, file := range files {
+		data, err := io.ReadAll(io.LimitReader(file, service.ImportTotalMaxSize-size+1))
+		if err != nil {
+			panic(common.NewErr("文件解析失败", err))
+		}
+		size += int64(len(data))
+		if size > service.ImportTotalMaxSize {
+			panic(common.NewError("上传文件总大小不可超过200MB"))
+		}
+		importFiles = append(importFiles, service.ImportFile{Name: headers[i].Filename, Data: data})
+	}
+
+	results := service.Import(importFiles, ctx.FormValue("bookId"), userId)
+	ctx.JSON(common.NewSuccessData("导入完成", results))
+}
@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 公开发布文档页面，服务端渲染markdown
+func DocumentPublishedView(ctx iris.Context) {
+	id := ctx.Params().Get("id")
+	ctx.ContentType("text/html; charset=utf-8")
+	err := service.DocumentPublishedPage(ctx.ResponseWriter(), id)
+	if err != nil {
+		middleware.Log.Error("渲染页面失败：", err)
+	}
+}
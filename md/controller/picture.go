@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"md/middleware"
 	"md/model/common"
 	"md/model/entity"
 	"md/service"
+	"net/http"
 
 	"github.com/kataras/iris/v12"
 )
@@ -35,11 +37,14 @@ func PictureUpload(ctx iris.Context) {
 	}
 	defer pictureFile.Close()
 
+	// 缩略图可选，未上传时由服务端生成
 	thumbnailFile, thumbnailInfo, err := ctx.FormFile("thumbnail")
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrMissingFile) {
 		panic(common.NewErr("图片解析失败", err))
 	}
-	defer thumbnailFile.Close()
+	if thumbnailFile != nil {
+		defer thumbnailFile.Close()
+	}
 
 	path, message := service.PictureUpload(pictureFile, thumbnailFile, pictureInfo, thumbnailInfo, userId)
 	ctx.JSON(common.NewSuccessData(message, path))
@@ -15,13 +15,21 @@ func InitRouter(app *iris.Application) {
 	// 允许跨域
 	app.UseRouter(cors.AllowAll())
 
+	// 公开发布文档页面
+	app.Get("/p/{id}", middleware.RequestLogger, DocumentPublishedView)
+
 	app.PartyFunc("/api", func(api iris.Party) {
 		// 开放接口
 		api.PartyFunc("/open", func(open iris.Party) {
 			open.Use(middleware.RequestLogger)
 
 			open.Get("/doc/get/{id}", DocumentGetPublished)
+			open.Get("/doc/html/{id}", DocumentHtmlPublished)
 			open.Post("/doc/page", DocumentPagePulished)
+			open.Get("/share/{token}", DocumentShareView)
+			open.Post("/share/{token}", DocumentShareView)
+			open.Get("/feed.rss", DocumentFeedRSS)
+			open.Get("/feed.atom", DocumentFeedAtom)
 		})
 
 		// token相关接口
@@ -31,18 +39,70 @@ func InitRouter(app *iris.Application) {
 
 			token.Post("/sign-up", SignUp)
 			token.Post("/sign-in", SignIn)
+			token.Post("/sign-in-totp", SignInTotp)
 			token.Post("/sign-out", SignOut)
 			token.Post("/refresh", TokenRefresh)
 		})
 
+		// 管理接口，仅管理员
+		api.PartyFunc("/admin", func(admin iris.Party) {
+			admin.Use(middleware.DataAuth)
+			admin.Use(middleware.AdminAuth)
+			admin.Use(middleware.RequestLogger)
+
+			// 指定用户的数据目录与数据库同步
+			admin.PartyFunc("/sync", func(sync iris.Party) {
+				sync.Post("/plan", SyncPlan)
+				sync.Post("/apply", SyncApply)
+			})
+
+			// 用户管理
+			admin.PartyFunc("/user", func(user iris.Party) {
+				user.Post("/list", AdminUserList)
+				user.Post("/add", AdminUserAdd)
+				user.Post("/update-role", AdminUserUpdateRole)
+				user.Post("/disable", AdminUserDisable)
+				user.Post("/enable", AdminUserEnable)
+				user.Post("/reset-password", AdminUserResetPassword)
+				user.Post("/reset-totp", AdminUserResetTotp)
+				user.Post("/delete", AdminUserDelete)
+			})
+
+			// 登录失败锁定
+			admin.PartyFunc("/sign-in", func(signIn iris.Party) {
+				signIn.Post("/locks", AdminSignInLockList)
+				signIn.Post("/unlock", AdminSignInUnlock)
+			})
+		})
+
 		// 数据接口
 		api.PartyFunc("/data", func(data iris.Party) {
 			data.Use(middleware.DataAuth)
 
-			// 更新密码
+			// 导出全部数据
+			data.Post("/export", Export)
+
+			// 导出公开发布文档为静态站点
+			data.Post("/export-site", ExportSite)
+
+			// 备份数据库和图片，仅管理员
+			data.Post("/backup", Backup)
+
+			// 导入markdown文件或zip压缩包
+			data.Post("/import", Import)
+
+			// 更新密码、两步验证、个人访问令牌
 			data.PartyFunc("/user", func(user iris.Party) {
 				user.Use(middleware.RequestLogger)
 				user.Post("/update-password", UserUpdatePassword)
+				user.Post("/totp/status", TotpStatus)
+				user.Post("/totp/setup", TotpSetup)
+				user.Post("/totp/enable", TotpEnable)
+				user.Post("/totp/disable", TotpDisable)
+				user.Post("/totp/recovery-codes", TotpRecoveryCodes)
+				user.Post("/tokens/list", ApiTokenList)
+				user.Post("/tokens/add", ApiTokenAdd)
+				user.Post("/tokens/revoke", ApiTokenRevoke)
 			})
 
 			// 目录
@@ -52,6 +112,18 @@ func InitRouter(app *iris.Application) {
 				book.Post("/update", BookUpdate)
 				book.Post("/delete", BookDelete)
 				book.Post("/list", BookList)
+				book.Post("/tree", BookTree)
+				book.Post("/move", BookMove)
+				book.Post("/reorder", BookReorder)
+				book.Post("/export", BookExport)
+			})
+
+			// 回收站
+			data.PartyFunc("/trash", func(trash iris.Party) {
+				trash.Use(middleware.RequestLogger)
+				trash.Post("/list", TrashList)
+				trash.Post("/restore", TrashRestore)
+				trash.Post("/purge", TrashPurge)
 			})
 
 			// 文档
@@ -61,8 +133,31 @@ func InitRouter(app *iris.Application) {
 				doc.Post("/update", DocumentUpdate)
 				doc.Post("/update-content", DocumentUpdateContent)
 				doc.Post("/delete", DocumentDelete)
+				doc.Post("/move", DocumentMove)
+				doc.Post("/reorder", DocumentReorder)
 				doc.Post("/list", DocumentList)
 				doc.Post("/get", DocumentGet)
+				doc.Post("/search", DocumentSearch)
+				doc.Post("/revisions", DocumentRevisionList)
+				doc.Post("/revision/get", DocumentRevisionGet)
+				doc.Post("/revision/diff", DocumentRevisionDiff)
+				doc.Post("/revision/restore", DocumentRevisionRestore)
+				doc.Post("/tag/list", DocumentTagList)
+				doc.Post("/tag/add", DocumentTagAdd)
+				doc.Post("/tag/remove", DocumentTagRemove)
+				doc.Post("/share/add", DocumentShareAdd)
+				doc.Post("/share/list", DocumentShareList)
+				doc.Post("/share/revoke", DocumentShareRevoke)
+				doc.Post("/share/delete", DocumentShareDelete)
+			})
+
+			// 标签
+			data.PartyFunc("/tag", func(tag iris.Party) {
+				tag.Use(middleware.RequestLogger)
+				tag.Post("/list", TagList)
+				tag.Post("/add", TagAdd)
+				tag.Post("/rename", TagRename)
+				tag.Post("/delete", TagDelete)
 			})
 
 			// 图片
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 生成指定用户的数据目录同步计划
+func SyncPlan(ctx iris.Context) {
+	condition := entity.SyncCondition{}
+	resolveParam(ctx, &condition)
+	ctx.JSON(common.NewSuccessData("查询成功", service.SyncPlan(condition.UserId)))
+}
+
+// 执行指定用户的数据目录同步
+func SyncApply(ctx iris.Context) {
+	condition := entity.SyncCondition{}
+	resolveParam(ctx, &condition)
+	ctx.JSON(common.NewSuccessData("同步成功", service.SyncApply(condition.UserId)))
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 查询标签列表
+func TagList(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.TagList(userId)))
+}
+
+// 添加标签
+func TagAdd(ctx iris.Context) {
+	tag := entity.Tag{}
+	resolveParam(ctx, &tag)
+	tag.UserId = middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("添加成功", service.TagAdd(tag)))
+}
+
+// 修改标签名称
+func TagRename(ctx iris.Context) {
+	tag := entity.Tag{}
+	resolveParam(ctx, &tag)
+	tag.UserId = middleware.CurrentUserId(ctx)
+	service.TagRename(tag)
+	ctx.JSON(common.NewSuccess("修改成功"))
+}
+
+// 删除标签
+func TagDelete(ctx iris.Context) {
+	tag := entity.Tag{}
+	resolveParam(ctx, &tag)
+	userId := middleware.CurrentUserId(ctx)
+	service.TagDelete(tag.Id, userId)
+	ctx.JSON(common.NewSuccess("删除成功"))
+}
+
+// 查询文档的标签列表
+func DocumentTagList(ctx iris.Context) {
+	condition := entity.DocumentTagCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentTagList(condition.DocumentId, userId)))
+}
+
+// 为文档添加标签
+func DocumentTagAdd(ctx iris.Context) {
+	condition := entity.DocumentTagCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("添加成功", service.DocumentTagAdd(condition, userId)))
+}
+
+// 移除文档的标签
+func DocumentTagRemove(ctx iris.Context) {
+	condition := entity.DocumentTagCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.DocumentTagRemove(condition, userId)
+	ctx.JSON(common.NewSuccess("移除成功"))
+}
@@ -20,7 +20,19 @@ func SignUp(ctx iris.Context) {
 func SignIn(ctx iris.Context) {
 	user := entity.User{}
 	resolveParam(ctx, &user)
-	tokenResult := service.SignIn(user)
+	tokenResult := service.SignIn(user, ctx.RemoteAddr())
+	if tokenResult.TotpRequired {
+		ctx.JSON(common.NewSuccessData("请输入两步验证码", tokenResult))
+		return
+	}
+	ctx.JSON(common.NewSuccessData("登录成功", tokenResult))
+}
+
+// 两步验证登录
+func SignInTotp(ctx iris.Context) {
+	condition := entity.TotpCondition{}
+	resolveParam(ctx, &condition)
+	tokenResult := service.SignInTotp(condition, ctx.RemoteAddr())
 	ctx.JSON(common.NewSuccessData("登录成功", tokenResult))
 }
 
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 查询回收站列表
+func TrashList(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.TrashList(userId)))
+}
+
+// 从回收站恢复
+func TrashRestore(ctx iris.Context) {
+	condition := entity.TrashCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.TrashRestore(condition, userId)
+	ctx.JSON(common.NewSuccess("恢复成功"))
+}
+
+// 从回收站彻底删除
+func TrashPurge(ctx iris.Context) {
+	condition := entity.TrashCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.TrashPurge(condition, userId)
+	ctx.JSON(common.NewSuccess("删除成功"))
+}
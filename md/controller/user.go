@@ -17,3 +17,65 @@ func UserUpdatePassword(ctx iris.Context) {
 	service.UserUpdatePassword(userCondition)
 	ctx.JSON(common.NewSuccess("更新成功"))
 }
+
+// 查询两步验证状态
+func TotpStatus(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.TotpStatusGet(userId)))
+}
+
+// 开始设置两步验证
+func TotpSetup(ctx iris.Context) {
+	condition := entity.TotpCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("设置成功", service.TotpSetup(condition, userId, ctx.RemoteAddr())))
+}
+
+// 确认并开启两步验证
+func TotpEnable(ctx iris.Context) {
+	condition := entity.TotpCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("开启成功", service.TotpEnable(condition, userId)))
+}
+
+// 关闭两步验证
+func TotpDisable(ctx iris.Context) {
+	condition := entity.TotpCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.TotpDisable(condition, userId, ctx.RemoteAddr())
+	ctx.JSON(common.NewSuccess("关闭成功"))
+}
+
+// 重新生成恢复码
+func TotpRecoveryCodes(ctx iris.Context) {
+	condition := entity.TotpCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("生成成功", service.TotpRecoveryCodes(condition, userId, ctx.RemoteAddr())))
+}
+
+// 查询个人访问令牌列表
+func ApiTokenList(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.ApiTokenList(userId)))
+}
+
+// 创建个人访问令牌
+func ApiTokenAdd(ctx iris.Context) {
+	condition := entity.ApiTokenCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("创建成功", service.ApiTokenAdd(condition, userId)))
+}
+
+// 撤销个人访问令牌
+func ApiTokenRevoke(ctx iris.Context) {
+	condition := entity.ApiTokenCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.ApiTokenRevoke(condition.Id, userId)
+	ctx.JSON(common.NewSuccess("撤销成功"))
+}
@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加个人访问令牌
+func ApiTokenAdd(tx *sqlx.Tx, apiToken entity.ApiToken) error {
+	sql := `insert into t_api_token (id,name,token_hash,token_prefix,scopes,expire_time,last_used_time,create_time,user_id) values (:id,:name,:token_hash,:token_prefix,:scopes,:expire_time,:last_used_time,:create_time,:user_id)`
+	_, err := tx.NamedExec(sql, apiToken)
+	return err
+}
+
+// 查询用户的个人访问令牌
+func ApiTokenList(db *sqlx.DB, userId string) ([]entity.ApiToken, error) {
+	sql := `select * from t_api_token where user_id=$1 order by create_time desc`
+	result := []entity.ApiToken{}
+	err := db.Select(&result, sql, userId)
+	return result, err
+}
+
+// 根据id查询个人访问令牌
+func ApiTokenGetById(tx *sqlx.Tx, id, userId string) (entity.ApiToken, error) {
+	sql := `select * from t_api_token where id=$1 and user_id=$2`
+	result := entity.ApiToken{}
+	err := tx.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 删除个人访问令牌
+func ApiTokenDeleteById(tx *sqlx.Tx, id, userId string) error {
+	sql := `delete from t_api_token where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 删除用户的全部个人访问令牌
+func ApiTokenDeleteByUser(tx *sqlx.Tx, userId string) error {
+	sql := `delete from t_api_token where user_id=$1`
+	_, err := tx.Exec(sql, userId)
+	return err
+}
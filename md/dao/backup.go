@@ -0,0 +1,106 @@
+package dao
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 合法的列名
+var columnNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// 使用sqlite在线备份生成一致的数据库快照文件
+func SqliteBackup(db *sqlx.DB, path string) error {
+	_, err := db.Exec(`VACUUM INTO $1`, path)
+	return err
+}
+
+// 校验sqlite数据库文件的完整性
+func SqliteIntegrityCheck(db *sqlx.DB) error {
+	var result string
+	err := db.Get(&result, `PRAGMA integrity_check`)
+	if err != nil {
+		return err
+	}
+	if result != "ok" {
+		return errors.New("数据库文件已损坏：" + result)
+	}
+	return nil
+}
+
+// 查询表的全部行，table只能使用程序内置的表名
+func TableRows(tx *sqlx.Tx, table string) ([]map[string]interface{}, error) {
+	rows, err := tx.Queryx(`select * from ` + table)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows)
+}
+
+// 按排序列分页查询表的行，table、orderBy只能使用程序内置的表名、列名
+func TableRowsPage(tx *sqlx.Tx, table, orderBy string, limit, offset int) ([]map[string]interface{}, error) {
+	rows, err := tx.Queryx(`select * from `+table+` order by `+orderBy+` limit $1 offset $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows)
+}
+
+// 查询表的行数
+func TableCount(tx *sqlx.Tx, table string) (int, error) {
+	var count int
+	err := tx.Get(&count, `select count(*) from `+table)
+	return count, err
+}
+
+// 将查询结果转为列名到值的映射，文本统一转为字符串
+func scanRows(rows *sqlx.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		row := map[string]interface{}{}
+		if err := rows.MapScan(row); err != nil {
+			return result, err
+		}
+		for k, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[k] = string(b)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// 清空表
+func TableClear(tx *sqlx.Tx, table string) error {
+	_, err := tx.Exec(`delete from ` + table)
+	return err
+}
+
+// 向表中插入一行，列名来自备份文件，需校验
+func TableInsert(tx *sqlx.Tx, table string, row map[string]interface{}) error {
+	columns := []string{}
+	for column := range row {
+		if !columnNameRegexp.MatchString(column) {
+			return errors.New("非法的列名：" + column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	placeholders := []string{}
+	params := []interface{}{}
+	for i, column := range columns {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		params = append(params, row[column])
+	}
+	sql := `insert into ` + table + ` (` + strings.Join(columns, ",") + `) values (` + strings.Join(placeholders, ",") + `)`
+	_, err := tx.Exec(sql, params...)
+	return err
+}
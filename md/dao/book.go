@@ -3,25 +3,94 @@ package dao
 import (
 	"github.com/jmoiron/sqlx"
 	"md/model/entity"
+	"md/util"
 	"sort"
-	"strconv"
-	"strings"
 )
 
 // 添加一级目录
 func BookAdd(tx *sqlx.Tx, book entity.Book) error {
-	sql := `insert into t_book (id,parent_id,name,create_time,user_id) values (:id,:parent_id,:name,:create_time,:user_id)`
+	sql := `insert into t_book (id,parent_id,name,create_time,user_id,sort_index) values (:id,:parent_id,:name,:create_time,:user_id,:sort_index)`
 	_, err := tx.NamedExec(sql, book)
 	return err
 }
 
 // 修改一级目录
-func BookUpdate(tx *sqlx.Tx, book entity.Book) error {
-	sql := `update t_book set name=:name where id=:id and user_id=:user_id`
-	_, err := tx.NamedExec(sql, book)
+func BookUpdate(tx *sqlx.Tx, book entity.Book) (bool, error) {
+	sql := `update t_book set name=:name where id=:id and user_id=:user_id and deleted_time=0`
+	result, err := tx.NamedExec(sql, book)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// 修改上级目录
+func BookUpdateParent(tx *sqlx.Tx, id, parentId string, sortIndex int64, userId string) error {
+	sql := `update t_book set parent_id=$1,sort_index=$2 where id=$3 and user_id=$4`
+	_, err := tx.Exec(sql, parentId, sortIndex, id, userId)
 	return err
 }
 
+// 修改目录排序序号
+func BookUpdateSortIndex(tx *sqlx.Tx, id string, sortIndex int64, userId string) error {
+	sql := `update t_book set sort_index=$1 where id=$2 and user_id=$3`
+	_, err := tx.Exec(sql, sortIndex, id, userId)
+	return err
+}
+
+// 查询上级目录下子目录的最大排序序号
+func BookMaxSortIndex(tx *sqlx.Tx, parentId, userId string) (int64, error) {
+	sql := `select coalesce(max(sort_index),0) from t_book where parent_id=$1 and user_id=$2 and deleted_time=0`
+	var result int64
+	err := tx.Get(&result, sql, parentId, userId)
+	return result, err
+}
+
+// 将目录移入回收站
+func BookSoftDelete(tx *sqlx.Tx, id string, deletedTime int64, userId string) (bool, error) {
+	sql := `update t_book set deleted_time=$1 where id=$2 and user_id=$3 and deleted_time=0`
+	result, err := tx.Exec(sql, deletedTime, id, userId)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// 从回收站恢复目录
+func BookRestore(tx *sqlx.Tx, id, userId string) error {
+	sql := `update t_book set deleted_time=0 where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 根据id查询回收站中的目录
+func BookGetDeleted(db *sqlx.DB, id, userId string) (entity.Book, error) {
+	sql := `select * from t_book where id=$1 and user_id=$2 and deleted_time>0`
+	result := entity.Book{}
+	err := db.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 查询回收站中的目录，deletedBefore大于0时只查询在该时间之前删除的目录，userId为空时查询全部用户
+func BookListDeleted(db *sqlx.DB, deletedBefore int64, userId string) ([]entity.Book, error) {
+	sqlCompletion := util.SqlCompletion{}
+	sqlCompletion.InitSql(`select * from t_book`)
+	sqlCompletion.Gt("deleted_time", 0, true)
+	if deletedBefore > 0 {
+		sqlCompletion.Lt("deleted_time", deletedBefore, true)
+	}
+	if userId != "" {
+		sqlCompletion.Eq("user_id", userId, true)
+	}
+	sqlCompletion.Order("deleted_time", false)
+
+	result := []entity.Book{}
+	err := db.Select(&result, sqlCompletion.GetSql(), sqlCompletion.GetParams()...)
+	return result, err
+}
+
 // 根据id删除一级目录
 func BookDeleteById(tx *sqlx.Tx, id, userId string) error {
 	sql := `delete from t_book where id=$1 and user_id=$2`
@@ -29,31 +98,46 @@ func BookDeleteById(tx *sqlx.Tx, id, userId string) error {
 	return err
 }
 
-// 查询一级目录列表
+// 查询目录列表，按层级深度优先排列，子目录排在上级目录的后面
 func BookList(db *sqlx.DB, userId string) ([]entity.Book, error) {
-	sql := `select * from t_book where user_id=$1 and parent_id=''`
+	sql := `select * from t_book where user_id=$1 and deleted_time=0`
 	books := []entity.Book{}
 	err := db.Select(&books, sql, userId)
+	if err != nil {
+		return books, err
+	}
 	sortBooks(books)
 
-	result := []entity.Book{}
-	// 查询每个根book的所有子集， 并且按顺序排序在根book的后面
+	children := map[string][]entity.Book{}
+	ids := map[string]bool{}
 	for _, book := range books {
-		result = append(result, book)
-		subBooks, err := BookByParentId(db, userId, book.Id)
-		if err != nil {
-			return result, err
+		children[book.ParentId] = append(children[book.ParentId], book)
+		ids[book.Id] = true
+	}
+
+	result := []entity.Book{}
+	var appendBooks func(parentId string)
+	appendBooks = func(parentId string) {
+		for _, book := range children[parentId] {
+			result = append(result, book)
+			appendBooks(book.Id)
 		}
-		sortBooks(subBooks)
-		result = append(result, subBooks...)
 	}
+	appendBooks("")
 
-	return result, err
+	// 上级目录不存在的目录放在最后，避免丢失
+	for _, book := range books {
+		if book.ParentId != "" && !ids[book.ParentId] {
+			result = append(result, book)
+			appendBooks(book.Id)
+		}
+	}
+	return result, nil
 }
 
 // 根据名称查询一级目录列表
 func BookListByName(tx *sqlx.Tx, name, userId string) ([]entity.Book, error) {
-	sql := `select * from t_book where user_id=$1 and name=$2`
+	sql := `select * from t_book where user_id=$1 and name=$2 and deleted_time=0`
 	result := []entity.Book{}
 	err := tx.Select(&result, sql, userId, name)
 	return result, err
@@ -61,7 +145,7 @@ func BookListByName(tx *sqlx.Tx, name, userId string) ([]entity.Book, error) {
 
 // 根据id查询二级目录
 func BookByParentId(db *sqlx.DB, userId string, parentId string) ([]entity.Book, error) {
-	sql := `select * from t_book where user_id=$1 and parent_id=$2`
+	sql := `select * from t_book where user_id=$1 and parent_id=$2 and deleted_time=0`
 	result := []entity.Book{}
 	err := db.Select(&result, sql, userId, parentId)
 	return result, err
@@ -69,28 +153,23 @@ func BookByParentId(db *sqlx.DB, userId string, parentId string) ([]entity.Book,
 
 // 查询一级目录
 func Book(db *sqlx.DB, id string) (entity.Book, error) {
-	sql := `select * from t_book where id=$1`
+	sql := `select * from t_book where id=$1 and deleted_time=0`
 	result := entity.Book{}
 	err := db.Get(&result, sql, id)
 	return result, err
 }
 
+// 查询全部用户的目录
+func BookListAll(db *sqlx.DB) ([]entity.Book, error) {
+	sql := `select * from t_book where deleted_time=0`
+	result := []entity.Book{}
+	err := db.Select(&result, sql)
+	return result, err
+}
+
 // 自定义目录排序逻辑
 func sortBooks(books []entity.Book) {
-	// 自定义排序逻辑
-	sort.Slice(books, func(i, j int) bool {
-		numA, errA := strconv.Atoi(strings.TrimSpace(books[i].Name)[:2])
-		numB, errB := strconv.Atoi(strings.TrimSpace(books[j].Name)[:2])
-
-		switch {
-		case errA != nil && errB != nil: // 如果两者都不是数字
-			return strings.ToLower(books[i].Name) < strings.ToLower(books[j].Name) // 按照字符串比较
-		case errA != nil: // A不是数字
-			return true // 非数字的元素排在数字元素后面
-		case errB != nil: // B不是数字
-			return false
-		default: // 都是数字
-			return numA < numB
-		}
+	sort.SliceStable(books, func(i, j int) bool {
+		return orderLess(books[i].SortIndex, books[j].SortIndex, books[i].Name, books[j].Name)
 	})
 }
@@ -13,25 +13,98 @@ import (
 
 // 添加文档
 func DocumentAdd(tx *sqlx.Tx, document entity.Document) error {
-	sql := `insert into t_document (id,name,content,type,published,create_time,update_time,book_id,user_id) values (:id,:name,:content,:type,:published,:create_time,:update_time,:book_id,:user_id)`
+	sql := `insert into t_document (id,name,content,type,published,create_time,update_time,book_id,user_id,sort_index) values (:id,:name,:content,:type,:published,:create_time,:update_time,:book_id,:user_id,:sort_index)`
 	_, err := tx.NamedExec(sql, document)
 	return err
 }
 
 // 修改文档基础信息
 func DocumentUpdate(tx *sqlx.Tx, document entity.Document) error {
-	sql := `update t_document set name=:name,published=:published,book_id=:book_id where id=:id and user_id=:user_id`
+	sql := `update t_document set name=:name,published=:published where id=:id and user_id=:user_id`
 	_, err := tx.NamedExec(sql, document)
 	return err
 }
 
 // 修改文档内容
 func DocumentUpdateContent(tx *sqlx.Tx, document entity.Document) error {
-	sql := `update t_document set content=:content,update_time=:update_time where id=:id and user_id=:user_id`
+	sql := `update t_document set content=:content,update_time=:update_time,version=version+1 where id=:id and user_id=:user_id`
 	_, err := tx.NamedExec(sql, document)
 	return err
 }
 
+// 更新文档内容，仅当版本号等于expectedVersion时更新，返回是否更新成功
+func DocumentUpdateContentIfUnchanged(tx *sqlx.Tx, document entity.Document, expectedVersion int64) (bool, error) {
+	sql := `update t_document set content=$1,update_time=$2,version=version+1 where id=$3 and user_id=$4 and version=$5`
+	result, err := tx.Exec(sql, document.Content, document.UpdateTime, document.Id, document.UserId, expectedVersion)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// 修改文档所属目录
+func DocumentUpdateBook(tx *sqlx.Tx, id, bookId string, sortIndex int64, userId string) error {
+	sql := `update t_document set book_id=$1,sort_index=$2 where id=$3 and user_id=$4`
+	_, err := tx.Exec(sql, bookId, sortIndex, id, userId)
+	return err
+}
+
+// 修改文档排序序号
+func DocumentUpdateSortIndex(tx *sqlx.Tx, id string, sortIndex int64, userId string) error {
+	sql := `update t_document set sort_index=$1 where id=$2 and user_id=$3`
+	_, err := tx.Exec(sql, sortIndex, id, userId)
+	return err
+}
+
+// 查询目录下文档的最大排序序号
+func DocumentMaxSortIndex(tx *sqlx.Tx, bookId, userId string) (int64, error) {
+	sql := `select coalesce(max(sort_index),0) from t_document where book_id=$1 and user_id=$2 and deleted_time=0`
+	var result int64
+	err := tx.Get(&result, sql, bookId, userId)
+	return result, err
+}
+
+// 将文档移入回收站
+func DocumentSoftDelete(tx *sqlx.Tx, id string, deletedTime int64, userId string) error {
+	sql := `update t_document set deleted_time=$1 where id=$2 and user_id=$3`
+	_, err := tx.Exec(sql, deletedTime, id, userId)
+	return err
+}
+
+// 从回收站恢复文档
+func DocumentRestore(tx *sqlx.Tx, id, userId string) error {
+	sql := `update t_document set deleted_time=0 where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 根据id查询回收站中的文档
+func DocumentGetDeleted(db *sqlx.DB, id, userId string) (entity.Document, error) {
+	sql := `select id,name,content,type,published,create_time,update_time,book_id,deleted_time from t_document where id=$1 and user_id=$2 and deleted_time>0`
+	result := entity.Document{}
+	err := db.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 查询回收站中的文档，deletedBefore大于0时只查询在该时间之前删除的文档，userId为空时查询全部用户
+func DocumentListDeleted(db *sqlx.DB, deletedBefore int64, userId string) ([]entity.Document, error) {
+	sqlCompletion := util.SqlCompletion{}
+	sqlCompletion.InitSql(`select id,name,type,create_time,update_time,book_id,user_id,deleted_time from t_document`)
+	sqlCompletion.Gt("deleted_time", 0, true)
+	if deletedBefore > 0 {
+		sqlCompletion.Lt("deleted_time", deletedBefore, true)
+	}
+	if userId != "" {
+		sqlCompletion.Eq("user_id", userId, true)
+	}
+	sqlCompletion.Order("deleted_time", false)
+
+	result := []entity.Document{}
+	err := db.Select(&result, sqlCompletion.GetSql(), sqlCompletion.GetParams()...)
+	return result, err
+}
+
 // 根据id删除文档
 func DocumentDeleteById(tx *sqlx.Tx, id, userId string) error {
 	sql := `delete from t_document where id=$1 and user_id=$2`
@@ -42,20 +115,64 @@ func DocumentDeleteById(tx *sqlx.Tx, id, userId string) error {
 // 查询文档列表
 func DocumentList(db *sqlx.DB, bookId, userId string) ([]entity.Document, error) {
 	sqlCompletion := util.SqlCompletion{}
-	sqlCompletion.InitSql(`select id,name,type,published,create_time,update_time,book_id from t_document`)
+	sqlCompletion.InitSql(`select id,name,type,published,create_time,update_time,book_id,sort_index from t_document`)
 	sqlCompletion.Eq("user_id", userId, true)
 	sqlCompletion.Eq("book_id", bookId, true)
+	sqlCompletion.Eq("deleted_time", 0, true)
 
 	result := []entity.Document{}
 	err := db.Select(&result, sqlCompletion.GetSql(), sqlCompletion.GetParams()...)
-	// 按名称升序
+	// 按排序序号、名称升序
 	sortDocuments(result)
 	return result, err
 }
 
+// 查询包含全部指定标签的文档列表，bookId为空时查询全部目录
+func DocumentListByTag(db *sqlx.DB, bookId string, tagIds []string, userId string) ([]entity.Document, error) {
+	sqlCompletion := util.SqlCompletion{}
+	sqlCompletion.InitSql(`select id,name,type,published,create_time,update_time,book_id,sort_index from t_document`)
+	sqlCompletion.Eq("user_id", userId, true)
+	sqlCompletion.Eq("deleted_time", 0, true)
+	if bookId != "" {
+		sqlCompletion.Eq("book_id", bookId, true)
+	}
+	subSql, subParams := documentTagSubSql(tagIds)
+	sqlCompletion.InSql("id", subSql, subParams, true)
+
+	result := []entity.Document{}
+	err := db.Select(&result, sqlCompletion.GetSql(), sqlCompletion.GetParams()...)
+	// 按排序序号、名称升序
+	sortDocuments(result)
+	return result, err
+}
+
+// 查询全部用户的文档，不包含内容
+func DocumentListAll(db *sqlx.DB) ([]entity.Document, error) {
+	sql := `select id,name,type,published,create_time,update_time,book_id,user_id from t_document where deleted_time=0`
+	result := []entity.Document{}
+	err := db.Select(&result, sql)
+	return result, err
+}
+
+// 查询内容中包含指定文本的文档，包含回收站中的文档
+func DocumentListByContent(db *sqlx.DB, text string) ([]entity.Document, error) {
+	sql := `select id,name,content,create_time,update_time,book_id,user_id,deleted_time,version from t_document where content like $1`
+	result := []entity.Document{}
+	err := db.Select(&result, sql, "%"+text+"%")
+	return result, err
+}
+
+// 查询用户的全部文档基础信息
+func DocumentListByUser(db *sqlx.DB, userId string) ([]entity.Document, error) {
+	sql := `select id,name,type,published,create_time,update_time,book_id,user_id from t_document where user_id=$1 and deleted_time=0`
+	result := []entity.Document{}
+	err := db.Select(&result, sql, userId)
+	return result, err
+}
+
 // 根据id查询文档
 func DocumentGetById(db *sqlx.DB, id, userId string) (entity.Document, error) {
-	sql := `select id,name,content,type,published,create_time,update_time,book_id from t_document where id=$1 and user_id=$2`
+	sql := `select id,name,content,type,published,create_time,update_time,book_id,version from t_document where id=$1 and user_id=$2 and deleted_time=0`
 	result := entity.Document{}
 	err := db.Get(&result, sql, id, userId)
 	return result, err
@@ -63,7 +180,7 @@ func DocumentGetById(db *sqlx.DB, id, userId string) (entity.Document, error) {
 
 // 根据name查询文档
 func DocumentGetName(db *sqlx.DB, name, userId string) ([]entity.Document, error) {
-	sql := `select id,name,content,type,published,create_time,update_time,book_id from t_document where name=$1 and user_id=$2`
+	sql := `select id,name,content,type,published,create_time,update_time,book_id,version from t_document where name=$1 and user_id=$2 and deleted_time=0`
 	result := []entity.Document{}
 	err := db.Select(&result, sql, name, userId)
 	return result, err
@@ -78,7 +195,7 @@ func DocumentClearBookId(tx *sqlx.Tx, bookId string) error {
 
 // 根据id查询公开发布文档
 func DocumentGetPublished(db *sqlx.DB, id string) (entity.Document, error) {
-	sql := `select id,name,content,type,published,create_time,update_time,book_id from t_document where id=$1 and published=true`
+	sql := `select id,name,content,type,published,create_time,update_time,book_id,version from t_document where id=$1 and published=true and deleted_time=0`
 	result := entity.Document{}
 	err := db.Get(&result, sql, id)
 	return result, err
@@ -88,12 +205,13 @@ func DocumentGetPublished(db *sqlx.DB, id string) (entity.Document, error) {
 func DocumentPagePulished(db *sqlx.DB, pageCondition common.PageCondition[entity.DocumentPageCondition]) ([]entity.DocumentPageResult, int, error) {
 	sqlCompletion := util.SqlCompletion{}
 	sqlCompletion.InitSql(
-		`select a.id, a.name, a.type, a.create_time, a.update_time, COALESCE(b.name, '') as username, COALESCE(c.name, '') as book_name 
+		`select a.id, a.name, a.type, a.create_time, a.update_time, COALESCE(b.name, '') as username, a.book_id, COALESCE(c.name, '') as book_name 
 		from t_document a 
 		left join t_user b on a.user_id = b.id 
 		left join t_book c on a.book_id = c.id`,
 	)
 	sqlCompletion.Eq("a.published", true, true)
+	sqlCompletion.Eq("a.deleted_time", 0, true)
 	if pageCondition.Condition.Username != "" {
 		sqlCompletion.Like("b.name", pageCondition.Condition.Username, true)
 	}
@@ -106,6 +224,9 @@ func DocumentPagePulished(db *sqlx.DB, pageCondition common.PageCondition[entity
 	if pageCondition.Condition.BookName != "" {
 		sqlCompletion.Like("c.name", pageCondition.Condition.BookName, true)
 	}
+	if pageCondition.Condition.Tag != "" {
+		sqlCompletion.InSql("a.id", `select dt.document_id from t_document_tag dt join t_tag t on t.id = dt.tag_id where t.name = ?`, []interface{}{pageCondition.Condition.Tag}, true)
+	}
 	sqlCompletion.Order("a.create_time", false)
 	sqlCompletion.Limit(pageCondition.Page.Current, pageCondition.Page.Size)
 
@@ -127,21 +248,40 @@ func DocumentPagePulished(db *sqlx.DB, pageCondition common.PageCondition[entity
 }
 
 // 自定义文档排序逻辑
-func sortDocuments(books []entity.Document) {
-	// 自定义排序逻辑
-	sort.Slice(books, func(i, j int) bool {
-		numA, errA := strconv.Atoi(strings.TrimSpace(books[i].Name)[:2])
-		numB, errB := strconv.Atoi(strings.TrimSpace(books[j].Name)[:2])
-
-		switch {
-		case errA != nil && errB != nil: // 如果两者都不是数字
-			return strings.ToLower(books[i].Name) < strings.ToLower(books[j].Name) // 按照字符串比较
-		case errA != nil: // A不是数字
-			return true // 非数字的元素排在数字元素后面
-		case errB != nil: // B不是数字
-			return false
-		default: // 都是数字
-			return numA < numB
-		}
+func sortDocuments(documents []entity.Document) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		return orderLess(documents[i].SortIndex, documents[j].SortIndex, documents[i].Name, documents[j].Name)
+	})
+}
+
+// 按排序序号升序，序号相同时按名称开头的数字升序，都不是数字时按名称升序
+func orderLess(indexA, indexB int64, nameA, nameB string) bool {
+	if indexA != indexB {
+		return indexA < indexB
+	}
+
+	numA, errA := strconv.Atoi(namePrefixNumber(nameA))
+	numB, errB := strconv.Atoi(namePrefixNumber(nameB))
+	switch {
+	case errA != nil && errB != nil: // 如果两者都不是数字
+		return strings.ToLower(nameA) < strings.ToLower(nameB) // 按照字符串比较
+	case errA != nil: // A不是数字
+		return true // 非数字的元素排在数字元素前面
+	case errB != nil: // B不是数字
+		return false
+	default: // 都是数字
+		return numA < numB
+	}
+}
+
+// 名称开头的数字部分，如 01-xxx 返回 01
+func namePrefixNumber(name string) string {
+	name = strings.TrimSpace(name)
+	end := strings.IndexFunc(name, func(r rune) bool {
+		return r < '0' || r > '9'
 	})
+	if end < 0 {
+		return name
+	}
+	return name[:end]
 }
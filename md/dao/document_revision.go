@@ -0,0 +1,51 @@
+package dao
+
+import (
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加文档历史版本
+func DocumentRevisionAdd(tx *sqlx.Tx, revision entity.DocumentRevision) error {
+	sql := `insert into t_document_revision (id,document_id,content,create_time,user_id) values (:id,:document_id,:content,:create_time,:user_id)`
+	_, err := tx.NamedExec(sql, revision)
+	return err
+}
+
+// 查询文档历史版本列表，不包含内容
+func DocumentRevisionList(db *sqlx.DB, documentId, userId string) ([]entity.DocumentRevision, error) {
+	sql := `select id,document_id,create_time from t_document_revision where document_id=$1 and user_id=$2 order by create_time desc,id desc`
+	result := []entity.DocumentRevision{}
+	err := db.Select(&result, sql, documentId, userId)
+	return result, err
+}
+
+// 根据id查询文档历史版本
+func DocumentRevisionGetById(db *sqlx.DB, id, userId string) (entity.DocumentRevision, error) {
+	sql := `select id,document_id,content,create_time from t_document_revision where id=$1 and user_id=$2`
+	result := entity.DocumentRevision{}
+	err := db.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 删除超出保留数量的文档历史版本
+func DocumentRevisionDeleteOutdated(tx *sqlx.Tx, documentId string, keep int) error {
+	sql := `delete from t_document_revision where document_id=$1 and id not in (select id from t_document_revision where document_id=$1 order by create_time desc,id desc limit $2)`
+	_, err := tx.Exec(sql, documentId, keep)
+	return err
+}
+
+// 根据文档id删除文档历史版本
+func DocumentRevisionDeleteByDocumentId(tx *sqlx.Tx, documentId, userId string) error {
+	sql := `delete from t_document_revision where document_id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, documentId, userId)
+	return err
+}
+
+// 将全部历史版本内容中的文本替换为新文本
+func DocumentRevisionReplaceContent(tx *sqlx.Tx, oldText, newText string) error {
+	sql := `update t_document_revision set content=replace(content,$1,$2) where content like $3`
+	_, err := tx.Exec(sql, oldText, newText, "%"+oldText+"%")
+	return err
+}
@@ -0,0 +1,170 @@
+package dao
+
+import (
+	"errors"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 文档全文检索接口，不同数据库使用各自的全文索引实现
+type DocumentSearcher interface {
+	// 检索当前用户名称或内容包含全部关键字、且包含全部标签的文档，按相关度降序
+	Search(db *sqlx.DB, keywords, tagIds []string, page common.Page, userId string) ([]entity.DocumentSearchResult, int, error)
+}
+
+// 摘要来源截取的字符数及关键字前保留的字符数，避免检索时读取完整内容
+const (
+	documentSnippetLength = 500
+	documentSnippetBefore = 100
+)
+
+var documentSearchers = map[string]DocumentSearcher{
+	"sqlite":   sqliteDocumentSearcher{},
+	"postgres": postgresDocumentSearcher{},
+}
+
+// 全文检索文档
+func DocumentSearch(db *sqlx.DB, keywords, tagIds []string, page common.Page, userId string) ([]entity.DocumentSearchResult, int, error) {
+	searcher, ok := documentSearchers[db.DriverName()]
+	if !ok {
+		return []entity.DocumentSearchResult{}, 0, errors.New("数据库不支持全文检索：" + db.DriverName())
+	}
+	return searcher.Search(db, keywords, tagIds, page, userId)
+}
+
+// sqlite全文检索，使用fts5 trigram索引，少于3个字符的关键字无法使用索引，退化为like匹配
+type sqliteDocumentSearcher struct{}
+
+func (sqliteDocumentSearcher) Search(db *sqlx.DB, keywords, tagIds []string, page common.Page, userId string) ([]entity.DocumentSearchResult, int, error) {
+	params := []interface{}{userId}
+	where := []string{"d.user_id=$1", "d.deleted_time=0"}
+	matchTerms := []string{}
+	for _, keyword := range keywords {
+		if util.StringLength(keyword) >= 3 {
+			matchTerms = append(matchTerms, `"`+strings.ReplaceAll(keyword, `"`, `""`)+`"`)
+			continue
+		}
+		params = append(params, util.EscapeLike(keyword))
+		placeholder := "$" + strconv.Itoa(len(params))
+		where = append(where, "(t_document_fts.name like '%'||"+placeholder+"||'%' escape '\\' or t_document_fts.content like '%'||"+placeholder+"||'%' escape '\\')")
+	}
+
+	where = append(where, documentTagWhere(tagIds, &params)...)
+
+	// bm25越小越相关，取反作为得分；名称列权重高于内容列
+	score := "0"
+	if len(matchTerms) > 0 {
+		params = append(params, strings.Join(matchTerms, " AND "))
+		where = append(where, "t_document_fts match $"+strconv.Itoa(len(params)))
+		score = "-bm25(t_document_fts, 0, 10.0, 1.0)"
+	}
+
+	from := ` from t_document_fts 
+		join t_document d on d.id = t_document_fts.id 
+		left join t_book b on b.id = d.book_id 
+		where ` + strings.Join(where, " and ")
+	snippet := documentSnippetSql(keywords, len(params), "instr", "max", "length")
+	sql := `select d.id, d.name, d.book_id, d.update_time, COALESCE(b.name, '') as book_name, ` + snippet + `, ` + score + ` as score` + from
+	return searchDocuments(db, sql, `select count(*) as count`+from, params, documentSnippetParams(keywords), page)
+}
+
+// postgres全文检索，使用tsvector GIN索引，同时以ilike匹配未分词的中文等连续文本，ilike使用pg_trgm三元组GIN索引
+type postgresDocumentSearcher struct{}
+
+func (postgresDocumentSearcher) Search(db *sqlx.DB, keywords, tagIds []string, page common.Page, userId string) ([]entity.DocumentSearchResult, int, error) {
+	vector := `(setweight(to_tsvector('simple', d.name), 'A') || setweight(to_tsvector('simple', d.content), 'B'))`
+
+	params := []interface{}{userId}
+	where := []string{"d.user_id=$1", "d.deleted_time=0"}
+	for _, keyword := range keywords {
+		params = append(params, keyword, util.EscapeLike(keyword))
+		placeholder := "$" + strconv.Itoa(len(params)-1)
+		likePlaceholder := "$" + strconv.Itoa(len(params))
+		where = append(where, "("+vector+" @@ plainto_tsquery('simple', "+placeholder+") or d.name ilike '%'||"+likePlaceholder+"||'%' escape '\\' or d.content ilike '%'||"+likePlaceholder+"||'%' escape '\\')")
+	}
+
+	where = append(where, documentTagWhere(tagIds, &params)...)
+
+	// 摘要及得分参数仅用于查询语句，不参与总数查询
+	selectParams := documentSnippetParams(keywords)
+	snippet := documentSnippetSql(keywords, len(params), "strpos", "greatest", "char_length")
+	keyword := strings.Join(keywords, " ")
+	selectParams = append(selectParams, keyword, util.EscapeLike(keyword))
+	placeholder := "$" + strconv.Itoa(len(params)+len(selectParams)-1)
+	likePlaceholder := "$" + strconv.Itoa(len(params)+len(selectParams))
+	score := "ts_rank(" + vector + ", plainto_tsquery('simple', " + placeholder + ")) + case when d.name ilike '%'||" + likePlaceholder + "||'%' escape '\\' then 1 else 0 end"
+
+	from := ` from t_document d 
+		left join t_book b on b.id = d.book_id 
+		where ` + strings.Join(where, " and ")
+	sql := `select d.id, d.name, d.book_id, d.update_time, COALESCE(b.name, '') as book_name, ` + snippet + `, ` + score + ` as score` + from
+	return searchDocuments(db, sql, `select count(*) as count`+from, params, selectParams, page)
+}
+
+// 摘要查询列，截取首个出现的关键字附近的内容，同时返回截取的起始位置和内容总长度
+// 参数 offset 为已有的参数个数，关键字参数由 documentSnippetParams 生成
+// 参数 index、greatest、length 为数据库对应的查找位置、取最大值和字符长度函数
+func documentSnippetSql(keywords []string, offset int, index, greatest, length string) string {
+	var position strings.Builder
+	position.WriteString("case")
+	for i := range keywords {
+		find := index + "(lower(d.content), lower($" + strconv.Itoa(offset+i+1) + "))"
+		position.WriteString(" when " + find + ">0 then " + find)
+	}
+	position.WriteString(" else 1 end")
+
+	start := greatest + "(1, " + position.String() + "-" + strconv.Itoa(documentSnippetBefore) + ")"
+	return "substr(d.content, " + start + ", " + strconv.Itoa(documentSnippetLength) + ") as content, " +
+		start + " as snippet_start, " + length + "(d.content) as content_length"
+}
+
+// 摘要查询列的关键字参数
+func documentSnippetParams(keywords []string) []interface{} {
+	params := make([]interface{}, len(keywords))
+	for i, keyword := range keywords {
+		params[i] = keyword
+	}
+	return params
+}
+
+// 执行检索及总数查询，scoreParams为仅查询语句使用的参数
+func searchDocuments(db *sqlx.DB, sql, countSql string, params, scoreParams []interface{}, page common.Page) ([]entity.DocumentSearchResult, int, error) {
+	selectParams := append(append([]interface{}{}, params...), scoreParams...)
+	sql += ` order by score desc, d.update_time desc`
+	if page.Current > 0 && page.Size > 0 {
+		sql += ` limit $` + strconv.Itoa(len(selectParams)+1) + ` offset $` + strconv.Itoa(len(selectParams)+2)
+		selectParams = append(selectParams, page.Size, page.Size*(page.Current-1))
+	}
+
+	// 查询分页数据
+	result := []entity.DocumentSearchResult{}
+	err := db.Select(&result, sql, selectParams...)
+	if err != nil {
+		return result, 0, err
+	}
+
+	// 查询总记录数
+	countResult := common.CountResult{}
+	err = db.Get(&countResult, countSql, params...)
+	if err != nil {
+		return result, 0, err
+	}
+
+	return result, countResult.Count, nil
+}
+
+// 标签过滤条件，无标签时返回空
+func documentTagWhere(tagIds []string, params *[]interface{}) []string {
+	if len(tagIds) == 0 {
+		return []string{}
+	}
+	subSql, subParams := documentTagSubSql(tagIds)
+	where := "d.id in (" + util.NumberPlaceholders(subSql, len(*params)) + ")"
+	*params = append(*params, subParams...)
+	return []string{where}
+}
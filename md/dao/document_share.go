@@ -0,0 +1,65 @@
+package dao
+
+import (
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加文档分享
+func DocumentShareAdd(tx *sqlx.Tx, share entity.DocumentShare) error {
+	sql := `insert into t_document_share (id,token,document_id,password,expire_time,view_count,revoked,create_time,user_id) values (:id,:token,:document_id,:password,:expire_time,:view_count,:revoked,:create_time,:user_id)`
+	_, err := tx.NamedExec(sql, share)
+	return err
+}
+
+// 查询文档的分享列表
+func DocumentShareList(db *sqlx.DB, documentId, userId string) ([]entity.DocumentShare, error) {
+	sql := `select * from t_document_share where document_id=$1 and user_id=$2 order by create_time desc`
+	result := []entity.DocumentShare{}
+	err := db.Select(&result, sql, documentId, userId)
+	return result, err
+}
+
+// 根据token查询文档分享
+func DocumentShareGetByToken(db *sqlx.DB, token string) (entity.DocumentShare, error) {
+	sql := `select * from t_document_share where token=$1`
+	result := entity.DocumentShare{}
+	err := db.Get(&result, sql, token)
+	return result, err
+}
+
+// 撤销文档分享
+func DocumentShareRevoke(tx *sqlx.Tx, id, userId string) error {
+	sql := `update t_document_share set revoked=true where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 修改文档分享的访问密码哈希
+func DocumentShareUpdatePassword(tx *sqlx.Tx, id, password string) error {
+	sql := `update t_document_share set password=$1 where id=$2`
+	_, err := tx.Exec(sql, password, id)
+	return err
+}
+
+// 增加文档分享访问次数
+func DocumentShareIncrView(tx *sqlx.Tx, id string) error {
+	sql := `update t_document_share set view_count=view_count+1 where id=$1`
+	_, err := tx.Exec(sql, id)
+	return err
+}
+
+// 根据id删除文档分享
+func DocumentShareDeleteById(tx *sqlx.Tx, id, userId string) error {
+	sql := `delete from t_document_share where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 根据文档id删除文档分享
+func DocumentShareDeleteByDocumentId(tx *sqlx.Tx, documentId, userId string) error {
+	sql := `delete from t_document_share where document_id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, documentId, userId)
+	return err
+}
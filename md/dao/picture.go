@@ -13,6 +13,7 @@ func PicturePage(db *sqlx.DB, page common.Page, userId string) ([]entity.Picture
 	sqlCompletion := util.SqlCompletion{}
 	sqlCompletion.InitSql(`select id,name,path,size,create_time from t_picture`)
 	sqlCompletion.Eq("user_id", userId, true)
+	sqlCompletion.Eq("deleted_time", 0, true)
 	sqlCompletion.Order("create_time", false)
 	sqlCompletion.Limit(page.Current, page.Size)
 
@@ -42,7 +43,7 @@ func PictureDeleteById(tx *sqlx.Tx, id, userId string) error {
 
 // 根据id查询图片
 func PictureGetById(tx *sqlx.Tx, id, userId string) (entity.Picture, error) {
-	sql := `select * from t_picture where id=$1 and user_id=$2`
+	sql := `select * from t_picture where id=$1 and user_id=$2 and deleted_time=0`
 	result := entity.Picture{}
 	err := tx.Get(&result, sql, id, userId)
 	return result, err
@@ -56,9 +57,17 @@ func PictureCountBySizeHash(tx *sqlx.Tx, size int64, hash string) (common.CountR
 	return result, err
 }
 
-// 根据文件大小、hash值查询相同图片
+// 根据文件路径查询引用同一文件的图片数量，不包含回收站中的图片
+func PictureCountByPath(tx *sqlx.Tx, path string) (common.CountResult, error) {
+	sql := `select count(*) as count from t_picture where path=$1 and deleted_time=0`
+	result := common.CountResult{}
+	err := tx.Get(&result, sql, path)
+	return result, err
+}
+
+// 根据文件大小、hash值查询相同图片，包含回收站中的图片，未删除的排在前面
 func PictureBySizeHash(db *sqlx.DB, size int64, hash string) ([]entity.Picture, error) {
-	sql := `select * from t_picture where size=$1 and hash=$2`
+	sql := `select * from t_picture where size=$1 and hash=$2 order by deleted_time,create_time`
 	result := []entity.Picture{}
 	err := db.Select(&result, sql, size, hash)
 	return result, err
@@ -66,7 +75,78 @@ func PictureBySizeHash(db *sqlx.DB, size int64, hash string) ([]entity.Picture,
 
 // 添加图片
 func PictureAdd(tx *sqlx.Tx, picture entity.Picture) error {
-	sql := `insert into t_picture (id,name,path,hash,size,create_time,user_id) values (:id,:name,:path,:hash,:size,:create_time,:user_id)`
+	sql := `insert into t_picture (id,name,path,hash,size,create_time,user_id,deleted_time) values (:id,:name,:path,:hash,:size,:create_time,:user_id,:deleted_time)`
 	_, err := tx.NamedExec(sql, picture)
 	return err
 }
+
+// 将图片移入回收站
+func PictureSoftDelete(tx *sqlx.Tx, id string, deletedTime int64, userId string) error {
+	sql := `update t_picture set deleted_time=$1 where id=$2 and user_id=$3`
+	_, err := tx.Exec(sql, deletedTime, id, userId)
+	return err
+}
+
+// 从回收站恢复图片
+func PictureRestore(tx *sqlx.Tx, id, userId string) error {
+	sql := `update t_picture set deleted_time=0 where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 根据id查询回收站中的图片
+func PictureGetDeleted(tx *sqlx.Tx, id, userId string) (entity.Picture, error) {
+	sql := `select * from t_picture where id=$1 and user_id=$2 and deleted_time>0`
+	result := entity.Picture{}
+	err := tx.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 根据文件路径查询回收站中引用同一文件的图片数量
+func PictureCountDeletedByPath(tx *sqlx.Tx, path string) (common.CountResult, error) {
+	sql := `select count(*) as count from t_picture where path=$1 and deleted_time>0`
+	result := common.CountResult{}
+	err := tx.Get(&result, sql, path)
+	return result, err
+}
+
+// 查询回收站中的图片，deletedBefore大于0时只查询在该时间之前删除的图片，userId为空时查询全部用户
+func PictureListDeleted(db *sqlx.DB, deletedBefore int64, userId string) ([]entity.Picture, error) {
+	sqlCompletion := util.SqlCompletion{}
+	sqlCompletion.InitSql(`select * from t_picture`)
+	sqlCompletion.Gt("deleted_time", 0, true)
+	if deletedBefore > 0 {
+		sqlCompletion.Lt("deleted_time", deletedBefore, true)
+	}
+	if userId != "" {
+		sqlCompletion.Eq("user_id", userId, true)
+	}
+	sqlCompletion.Order("deleted_time", false)
+
+	result := []entity.Picture{}
+	err := db.Select(&result, sqlCompletion.GetSql(), sqlCompletion.GetParams()...)
+	return result, err
+}
+
+// 查询用户的全部图片，包括回收站中的
+func PictureListByUser(db *sqlx.DB, userId string) ([]entity.Picture, error) {
+	sql := `select * from t_picture where user_id=$1`
+	result := []entity.Picture{}
+	err := db.Select(&result, sql, userId)
+	return result, err
+}
+
+// 查询全部用户的图片，包含回收站中的图片
+func PictureListAll(db *sqlx.DB) ([]entity.Picture, error) {
+	sql := `select * from t_picture order by create_time`
+	result := []entity.Picture{}
+	err := db.Select(&result, sql)
+	return result, err
+}
+
+// 将引用同一文件的图片改为引用另一文件
+func PictureUpdatePath(tx *sqlx.Tx, oldPath, newPath string) error {
+	sql := `update t_picture set path=$1 where path=$2`
+	_, err := tx.Exec(sql, newPath, oldPath)
+	return err
+}
@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加恢复码，code为恢复码的哈希
+func RecoveryCodeAdd(tx *sqlx.Tx, userId string, codes []string, createTime int64) error {
+	sql := `insert into t_recovery_code (user_id,code,create_time) values ($1,$2,$3)`
+	for _, code := range codes {
+		if _, err := tx.Exec(sql, userId, code, createTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 使用恢复码，存在时删除，返回是否存在
+func RecoveryCodeUse(tx *sqlx.Tx, userId, code string) (bool, error) {
+	sql := `delete from t_recovery_code where user_id=$1 and code=$2`
+	result, err := tx.Exec(sql, userId, code)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// 删除用户的全部恢复码
+func RecoveryCodeDeleteByUser(tx *sqlx.Tx, userId string) error {
+	sql := `delete from t_recovery_code where user_id=$1`
+	_, err := tx.Exec(sql, userId)
+	return err
+}
+
+// 查询用户剩余的恢复码数量
+func RecoveryCodeCount(db *sqlx.DB, userId string) (int, error) {
+	sql := `select count(*) from t_recovery_code where user_id=$1`
+	var count int
+	err := db.Get(&count, sql, userId)
+	return count, err
+}
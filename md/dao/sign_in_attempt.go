@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"errors"
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 查询登录失败记录
+func SignInAttemptGet(tx interface{}, attemptType entity.SignInAttemptType, name string) (entity.SignInAttempt, error) {
+	sql := `select * from t_sign_in_attempt where type=$1 and name=$2`
+	result := entity.SignInAttempt{}
+	var err error
+	switch tx := tx.(type) {
+	case *sqlx.Tx:
+		err = tx.Get(&result, sql, attemptType, name)
+	case *sqlx.DB:
+		err = tx.Get(&result, sql, attemptType, name)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询锁定截止时间晚于now的记录
+func SignInAttemptListLocked(db *sqlx.DB, now int64) ([]entity.SignInAttempt, error) {
+	sql := `select * from t_sign_in_attempt where locked_until>$1 order by locked_until desc`
+	result := []entity.SignInAttempt{}
+	err := db.Select(&result, sql, now)
+	return result, err
+}
+
+// 失败次数加1，不存在时新增记录，在一条语句中完成避免并发时丢失次数，返回加1后的失败次数
+func SignInAttemptIncrease(tx *sqlx.Tx, attemptType entity.SignInAttemptType, name string, now int64) (int64, error) {
+	sql := `insert into t_sign_in_attempt (type,name,failed_count,last_failed_time,locked_until) values ($1,$2,1,$3,0)
+	on conflict (type,name) do update set failed_count=t_sign_in_attempt.failed_count+1,last_failed_time=excluded.last_failed_time
+	returning failed_count`
+	var result int64
+	err := tx.Get(&result, sql, attemptType, name, now)
+	return result, err
+}
+
+// 设置锁定截止时间
+func SignInAttemptLock(tx *sqlx.Tx, attemptType entity.SignInAttemptType, name string, lockedUntil int64) error {
+	sql := `update t_sign_in_attempt set locked_until=$1 where type=$2 and name=$3`
+	_, err := tx.Exec(sql, lockedUntil, attemptType, name)
+	return err
+}
+
+// 删除登录失败记录
+func SignInAttemptDelete(tx *sqlx.Tx, attemptType entity.SignInAttemptType, name string) error {
+	sql := `delete from t_sign_in_attempt where type=$1 and name=$2`
+	_, err := tx.Exec(sql, attemptType, name)
+	return err
+}
+
+// 删除最后失败时间和锁定截止时间均早于before的记录
+func SignInAttemptDeleteExpired(tx *sqlx.Tx, before int64) error {
+	sql := `delete from t_sign_in_attempt where last_failed_time<$1 and locked_until<$1`
+	_, err := tx.Exec(sql, before)
+	return err
+}
@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"md/model/common"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 查询用户上次同步时间，从未同步时返回0
+func SyncTimeGet(db *sqlx.DB, userId string) (int64, error) {
+	sql := `select count(*) as count from t_sync where user_id=$1`
+	countResult := common.CountResult{}
+	err := db.Get(&countResult, sql, userId)
+	if err != nil || countResult.Count == 0 {
+		return 0, err
+	}
+
+	var syncTime int64
+	err = db.Get(&syncTime, `select sync_time from t_sync where user_id=$1`, userId)
+	return syncTime, err
+}
+
+// 保存用户同步时间
+func SyncTimeSave(tx *sqlx.Tx, userId string, syncTime int64) error {
+	_, err := tx.Exec(`delete from t_sync where user_id=$1`, userId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`insert into t_sync (user_id,sync_time) values ($1,$2)`, userId, syncTime)
+	return err
+}
+
+// 查询用户上次同步后两边都存在的路径
+func SyncPathList(db *sqlx.DB, userId string) ([]string, error) {
+	sql := `select path from t_sync_path where user_id=$1`
+	result := []string{}
+	err := db.Select(&result, sql, userId)
+	return result, err
+}
+
+// 保存用户同步后两边都存在的路径
+func SyncPathSave(tx *sqlx.Tx, userId string, paths []string) error {
+	_, err := tx.Exec(`delete from t_sync_path where user_id=$1`, userId)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		_, err = tx.Exec(`insert into t_sync_path (user_id,path) values ($1,$2)`, userId, p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
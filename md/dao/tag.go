@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"md/model/entity"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加标签
+func TagAdd(tx *sqlx.Tx, tag entity.Tag) error {
+	sql := `insert into t_tag (id,name,create_time,user_id) values (:id,:name,:create_time,:user_id)`
+	_, err := tx.NamedExec(sql, tag)
+	return err
+}
+
+// 修改标签名称
+func TagUpdateName(tx *sqlx.Tx, tag entity.Tag) error {
+	sql := `update t_tag set name=:name where id=:id and user_id=:user_id`
+	_, err := tx.NamedExec(sql, tag)
+	return err
+}
+
+// 根据id删除标签
+func TagDeleteById(tx *sqlx.Tx, id, userId string) error {
+	sql := `delete from t_tag where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 根据id查询标签
+func TagGetById(db *sqlx.DB, id, userId string) (entity.Tag, error) {
+	sql := `select id,name,create_time,user_id from t_tag where id=$1 and user_id=$2`
+	result := entity.Tag{}
+	err := db.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 根据名称查询标签
+func TagListByName(tx *sqlx.Tx, name, userId string) ([]entity.Tag, error) {
+	sql := `select id,name,create_time,user_id from t_tag where name=$1 and user_id=$2`
+	result := []entity.Tag{}
+	err := tx.Select(&result, sql, name, userId)
+	return result, err
+}
+
+// 查询标签列表及各标签下的文档数量，按名称升序
+func TagList(db *sqlx.DB, userId string) ([]entity.Tag, error) {
+	sql := `select t.id,t.name,t.create_time,t.user_id,(select count(*) from t_document_tag dt join t_document d on d.id=dt.document_id where dt.tag_id=t.id and d.deleted_time=0) as document_count from t_tag t where t.user_id=$1 order by t.name`
+	result := []entity.Tag{}
+	err := db.Select(&result, sql, userId)
+	return result, err
+}
+
+// 为文档添加标签，已存在时忽略
+func DocumentTagAdd(tx *sqlx.Tx, documentId, tagId string) error {
+	sql := `insert into t_document_tag (document_id,tag_id) values ($1,$2) on conflict do nothing`
+	_, err := tx.Exec(sql, documentId, tagId)
+	return err
+}
+
+// 移除文档的标签
+func DocumentTagDelete(tx *sqlx.Tx, documentId, tagId string) error {
+	sql := `delete from t_document_tag where document_id=$1 and tag_id=$2`
+	_, err := tx.Exec(sql, documentId, tagId)
+	return err
+}
+
+// 根据标签id删除文档标签关联
+func DocumentTagDeleteByTagId(tx *sqlx.Tx, tagId string) error {
+	sql := `delete from t_document_tag where tag_id=$1`
+	_, err := tx.Exec(sql, tagId)
+	return err
+}
+
+// 根据文档id删除文档标签关联
+func DocumentTagDeleteByDocumentId(tx *sqlx.Tx, documentId string) error {
+	sql := `delete from t_document_tag where document_id=$1`
+	_, err := tx.Exec(sql, documentId)
+	return err
+}
+
+// 查询文档的标签列表
+func DocumentTagList(db *sqlx.DB, documentId, userId string) ([]entity.Tag, error) {
+	sql := `select t.id,t.name,t.create_time,t.user_id from t_tag t join t_document_tag dt on dt.tag_id=t.id where dt.document_id=$1 and t.user_id=$2 order by t.name`
+	result := []entity.Tag{}
+	err := db.Select(&result, sql, documentId, userId)
+	return result, err
+}
+
+// 包含全部指定标签的文档id子查询，使用 ? 作为参数占位符
+func documentTagSubSql(tagIds []string) (string, []interface{}) {
+	// 重复的标签id去重，否则匹配数量无法等于标签数量
+	params := make([]interface{}, 0, len(tagIds))
+	for _, tagId := range tagIds {
+		if !slices.Contains(params, interface{}(tagId)) {
+			params = append(params, tagId)
+		}
+	}
+	sql := `select document_id from t_document_tag where tag_id in (` + strings.TrimSuffix(strings.Repeat("?,", len(params)), ",") + `) group by document_id having count(*)=` + strconv.Itoa(len(params))
+	return sql, params
+}
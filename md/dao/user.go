@@ -10,7 +10,7 @@ import (
 
 // 添加用户
 func UserAdd(tx *sqlx.Tx, user entity.User) error {
-	sql := `insert into t_user (id,name,password,create_time) values (:id,:name,:password,:create_time)`
+	sql := `insert into t_user (id,name,password,create_time,role) values (:id,:name,:password,:create_time,:role)`
 	_, err := tx.NamedExec(sql, user)
 	return err
 }
@@ -22,6 +22,13 @@ func UserResetPassword(tx *sqlx.Tx, user entity.User) error {
 	return err
 }
 
+// 更新密码哈希，仅当密码未被修改时更新
+func UserRehashPassword(tx *sqlx.Tx, id, oldPassword, newPassword string) error {
+	sql := `update t_user set password=$1 where id=$2 and password=$3`
+	_, err := tx.Exec(sql, newPassword, id, oldPassword)
+	return err
+}
+
 // 根据id查询用户
 func UserGetById(tx interface{}, id string) (entity.User, error) {
 	sql := `select * from t_user where id=$1`
@@ -61,3 +68,76 @@ func UserCount(tx *sqlx.Tx) (common.CountResult, error) {
 	err := tx.Get(&result, sql)
 	return result, err
 }
+
+// 查询全部用户，不含密码
+func UserList(db *sqlx.DB) ([]entity.User, error) {
+	sql := `select id,name,create_time,role,disabled,totp_enabled from t_user order by create_time`
+	result := []entity.User{}
+	err := db.Select(&result, sql)
+	return result, err
+}
+
+// 查询未禁用的管理员数量
+func UserCountAdmin(tx *sqlx.Tx) (common.CountResult, error) {
+	sql := `select count(*) as count from t_user where role=$1 and disabled=$2`
+	result := common.CountResult{}
+	err := tx.Get(&result, sql, common.RoleAdmin, false)
+	return result, err
+}
+
+// 修改用户角色
+func UserUpdateRole(tx *sqlx.Tx, id, role string) error {
+	sql := `update t_user set role=$1 where id=$2`
+	_, err := tx.Exec(sql, role, id)
+	return err
+}
+
+// 修改用户禁用状态
+func UserUpdateDisabled(tx *sqlx.Tx, id string, disabled bool) error {
+	sql := `update t_user set disabled=$1 where id=$2`
+	_, err := tx.Exec(sql, disabled, id)
+	return err
+}
+
+// 删除用户及其全部数据
+func UserDeleteById(tx *sqlx.Tx, id string) error {
+	sqls := []string{
+		`delete from t_document_tag where document_id in (select id from t_document where user_id=$1)`,
+		`delete from t_document_revision where user_id=$1`,
+		`delete from t_document_share where user_id=$1`,
+		`delete from t_document where user_id=$1`,
+		`delete from t_book where user_id=$1`,
+		`delete from t_picture where user_id=$1`,
+		`delete from t_tag where user_id=$1`,
+		`delete from t_sync where user_id=$1`,
+		`delete from t_sync_path where user_id=$1`,
+		`delete from t_token where user_id=$1`,
+		`delete from t_recovery_code where user_id=$1`,
+		`delete from t_api_token where user_id=$1`,
+		`delete from t_user where id=$1`,
+	}
+	for _, sql := range sqls {
+		if _, err := tx.Exec(sql, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 修改两步验证密钥和开启状态，同时重置最后使用的时间步
+func UserUpdateTotp(tx *sqlx.Tx, id, secret string, enabled bool) error {
+	sql := `update t_user set totp_secret=$1,totp_enabled=$2,totp_last_step=0 where id=$3`
+	_, err := tx.Exec(sql, secret, enabled, id)
+	return err
+}
+
+// 记录最后使用的验证码时间步，仅当step大于已记录的时间步时更新，返回是否更新成功
+func UserUpdateTotpStep(tx *sqlx.Tx, id string, step int64) (bool, error) {
+	sql := `update t_user set totp_last_step=$1 where id=$2 and totp_last_step<$1`
+	result, err := tx.Exec(sql, step, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
@@ -8,8 +8,10 @@ import (
 	"md/controller"
 	"md/middleware"
 	"md/model/common"
+	"md/service"
 	"md/util"
 	"net/http"
+	"time"
 )
 
 //go:embed web
@@ -26,7 +28,13 @@ func init() {
 	flag.StringVar(&common.PostgresUser, "pg_user", "postgres", "postgres用户")
 	flag.StringVar(&common.PostgresPassword, "pg_password", "123456", "postgres密码")
 	flag.StringVar(&common.PostgresDB, "pg_db", "blog-dev", "postgres数据库名")
-	flag.BoolVar(&common.RefreshDb, "re_db", false, "刷新数据库数据")
+	flag.StringVar(&common.TokenStore, "token_store", "db", "token存储方式：memory（内存，重启后失效） / db（数据库）")
+	flag.IntVar(&common.RevisionKeep, "revision_keep", 50, "每个文档保留的历史版本数量，小于等于0时不限制")
+	flag.StringVar(&common.Sync, "sync", "", "同步数据目录与数据库后退出：plan（仅输出同步计划） / apply（执行同步）")
+	flag.StringVar(&common.SyncUser, "sync_user", "admin", "同步数据目录的用户名")
+	flag.BoolVar(&common.MigrateOnly, "migrate-only", false, "执行数据库迁移后退出")
+	flag.BoolVar(&common.MigrateStatus, "migrate-status", false, "输出数据库迁移状态后退出，不执行迁移")
+	flag.IntVar(&common.TrashDays, "trash_days", 30, "回收站保留天数，超过后自动彻底删除，小于等于0时不自动清理")
 	flag.Parse()
 
 	// 固定配置
@@ -34,6 +42,7 @@ func init() {
 	common.ResourceName = ""
 	common.PictureName = "picture"
 	common.ThumbnailName = "thumbnail"
+	common.TrashName = ".trash"
 }
 
 func main() {
@@ -62,12 +71,64 @@ func main() {
 		return
 	}
 
+	// 命令行在sqlite与postgres之间复制数据，需在连接数据库前处理
+	if flag.Arg(0) == "copy-db" {
+		copyDb(flag.Args()[1:])
+		return
+	}
+
 	// 初始化数据库连接
 	err = middleware.InitDB()
 	if err != nil {
 		return
 	}
 
+	// 命令行数据库迁移
+	if common.MigrateOnly || common.MigrateStatus {
+		migrateStatus()
+		return
+	}
+
+	// 命令行备份、恢复
+	switch flag.Arg(0) {
+	case "backup":
+		backup(flag.Args()[1:])
+		return
+	case "restore":
+		restore(flag.Args()[1:])
+		return
+	}
+
+	// 命令行导出静态站点
+	if flag.Arg(0) == "export-site" {
+		exportSite(flag.Args()[1:])
+		return
+	}
+
+	// 命令行合并相同内容的图片文件
+	if flag.Arg(0) == "dedup-pictures" {
+		dedupPictures()
+		return
+	}
+
+	// 命令行同步数据目录
+	if common.Sync != "" {
+		err = service.SyncCommand(common.Sync, common.SyncUser)
+		if err != nil {
+			middleware.Log.Error("同步失败：", err)
+		}
+		return
+	}
+
+	// 初始化token存储
+	err = middleware.InitTokenStore(common.TokenStore)
+	if err != nil {
+		return
+	}
+
+	// 定时清理回收站
+	service.InitTrashPurge(common.TrashDays)
+
 	// 初始化API路由
 	controller.InitRouter(app)
 
@@ -89,3 +150,92 @@ func main() {
 	middleware.Log.Infof("启动服务: {%s}", common.Port)
 	app.Logger().Error(app.Run(iris.Addr(":" + common.Port)))
 }
+
+// 输出数据库迁移状态
+func migrateStatus() {
+	statuses, err := middleware.MigrationStatusList()
+	if err != nil {
+		middleware.Log.Error("查询数据库迁移状态失败：", err)
+		return
+	}
+	for _, status := range statuses {
+		if status.AppliedTime > 0 {
+			middleware.Log.Infof("已执行: {%d %s} %s", status.Version, status.Name, time.UnixMilli(status.AppliedTime).Format(time.DateTime))
+		} else {
+			middleware.Log.Infof("未执行: {%d %s}", status.Version, status.Name)
+		}
+	}
+}
+
+// 备份子命令，如 md backup -out backup.zip
+func backup(args []string) {
+	backupFlag := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := backupFlag.String("out", "backup-"+time.Now().Format("20060102150405")+".zip", "备份文件路径")
+	backupFlag.Parse(args)
+
+	err := service.BackupFile(*out)
+	if err != nil {
+		middleware.Log.Error("备份失败：", err)
+		return
+	}
+	middleware.Log.Infof("备份: {%s}", *out)
+}
+
+// 恢复子命令，如 md restore -in backup.zip，会覆盖当前数据库中的全部数据，需先停止服务
+func restore(args []string) {
+	restoreFlag := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := restoreFlag.String("in", "", "备份文件路径")
+	restoreFlag.Parse(args)
+
+	if *in == "" {
+		middleware.Log.Error("请指定备份文件路径：-in")
+		return
+	}
+	err := service.Restore(*in)
+	if err != nil {
+		middleware.Log.Error("恢复失败：", err)
+	}
+}
+
+// 复制数据库子命令，如 md -pg_host 127.0.0.1 copy-db -from sqlite -to postgres
+// postgres连接使用-pg_*参数，sqlite使用数据目录中的md.db，需先停止服务
+func copyDb(args []string) {
+	copyFlag := flag.NewFlagSet("copy-db", flag.ExitOnError)
+	from := copyFlag.String("from", "sqlite", "源数据库：sqlite / postgres")
+	to := copyFlag.String("to", "postgres", "目标数据库：sqlite / postgres")
+	overwrite := copyFlag.Bool("overwrite", false, "目标数据库已有数据时清空后覆盖")
+	batch := copyFlag.Int("batch", 500, "每批复制的行数")
+	copyFlag.Parse(args)
+
+	err := service.CopyDb(*from, *to, *overwrite, *batch)
+	if err != nil {
+		middleware.Log.Error("复制数据库失败：", err)
+		return
+	}
+	middleware.Log.Infof("复制数据库: {%s -> %s}", *from, *to)
+}
+
+// 合并相同内容的图片文件子命令，如 md dedup-pictures，需先停止服务
+func dedupPictures() {
+	count, err := service.PictureDedup()
+	if err != nil {
+		middleware.Log.Error("合并图片失败：", err)
+		return
+	}
+	middleware.Log.Infof("合并图片: {%d}", count)
+}
+
+// 导出静态站点子命令，如 md export-site -out site -url https://example.com
+func exportSite(args []string) {
+	exportFlag := flag.NewFlagSet("export-site", flag.ExitOnError)
+	out := exportFlag.String("out", "site", "静态站点输出目录")
+	url := exportFlag.String("url", "", "站点部署地址，用于sitemap.xml和rss.xml中的绝对链接")
+	exportFlag.Parse(args)
+
+	err := service.ExportSiteDir(*out, *url)
+	if err != nil {
+		middleware.Log.Error("导出静态站点失败：", err)
+		return
+	}
+	middleware.Log.Infof("导出静态站点: {%s}", *out)
+}
@@ -6,21 +6,38 @@ import (
 	"unicode/utf8"
 
 	"github.com/kataras/iris/v12"
-	"github.com/muesli/cache2go"
 )
 
 // 数据接口授权
 func DataAuth(ctx iris.Context) {
 	token := resolveHeader(ctx, "Bearer")
 
-	// 检验缓存中是否存在此token
-	if !cache2go.Cache(common.AccessTokenCache).Exists(token) {
+	// 个人访问令牌
+	if strings.HasPrefix(token, common.ApiTokenPrefix) {
+		apiTokenAuth(ctx, token)
+		ctx.Next()
+		return
+	}
+
+	// 检验是否存在此token
+	if _, err := Tokens.Get(common.AccessTokenCache, token); err != nil {
 		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
 	}
 
 	ctx.Next()
 }
 
+// 管理接口授权，在DataAuth之后校验当前用户为未禁用的管理员
+func AdminAuth(ctx iris.Context) {
+	var role string
+	err := Db.Get(&role, `select role from t_user where id=$1 and disabled=$2`, CurrentUserId(ctx), false)
+	if err != nil || role != common.RoleAdmin {
+		panic(common.NewErrorCode(common.HttpForbidden, "无管理员权限"))
+	}
+
+	ctx.Next()
+}
+
 // TokenAuth 函数用于进行 token 相关接口的认证授权
 // 参数 ctx 表示 Iris 的上下文对象
 func TokenAuth(ctx iris.Context) {
@@ -45,12 +62,16 @@ func TokenAuth(ctx iris.Context) {
 
 // 获取当前登录用户id
 func CurrentUserId(ctx iris.Context) string {
+	// 个人访问令牌认证时已保存用户id
+	if userId := ctx.Values().GetString(currentUserIdKey); userId != "" {
+		return userId
+	}
+
 	token := resolveHeader(ctx, "Bearer")
-	res, err := cache2go.Cache(common.AccessTokenCache).Value(token)
+	tokenCache, err := Tokens.Get(common.AccessTokenCache, token)
 	if err != nil {
 		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
 	}
-	tokenCache := res.Data().(*common.TokenCache)
 	if tokenCache.Id == "" {
 		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
 	}
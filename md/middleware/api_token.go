@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"md/model/common"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 请求上下文中保存的当前用户id和个人访问令牌权限
+const (
+	currentUserIdKey = "currentUserId"
+	apiTokenScopeKey = "apiTokenScopes"
+)
+
+// 个人访问令牌最后使用时间的更新间隔，避免每个请求都写数据库
+const apiTokenTouchInterval = time.Minute
+
+// 个人访问令牌可以访问的数据接口及所需权限，未列出的接口（如修改密码、管理令牌、备份、同步、回收站、管理接口）不可访问
+var apiTokenScopes = map[string]string{
+	"/api/data/export":               common.ScopeDocRead,
+	"/api/data/export-site":          common.ScopeDocRead,
+	"/api/data/book/list":            common.ScopeDocRead,
+	"/api/data/book/tree":            common.ScopeDocRead,
+	"/api/data/book/export":          common.ScopeDocRead,
+	"/api/data/doc/list":             common.ScopeDocRead,
+	"/api/data/doc/get":              common.ScopeDocRead,
+	"/api/data/doc/search":           common.ScopeDocRead,
+	"/api/data/doc/revisions":        common.ScopeDocRead,
+	"/api/data/doc/revision/get":     common.ScopeDocRead,
+	"/api/data/doc/revision/diff":    common.ScopeDocRead,
+	"/api/data/doc/tag/list":         common.ScopeDocRead,
+	"/api/data/tag/list":             common.ScopeDocRead,
+	"/api/data/pic/page":             common.ScopeDocRead,
+	"/api/data/import":               common.ScopeDocWrite,
+	"/api/data/book/add":             common.ScopeDocWrite,
+	"/api/data/book/update":          common.ScopeDocWrite,
+	"/api/data/book/delete":          common.ScopeDocWrite,
+	"/api/data/book/move":            common.ScopeDocWrite,
+	"/api/data/book/reorder":         common.ScopeDocWrite,
+	"/api/data/doc/add":              common.ScopeDocWrite,
+	"/api/data/doc/update":           common.ScopeDocWrite,
+	"/api/data/doc/update-content":   common.ScopeDocWrite,
+	"/api/data/doc/delete":           common.ScopeDocWrite,
+	"/api/data/doc/move":             common.ScopeDocWrite,
+	"/api/data/doc/reorder":          common.ScopeDocWrite,
+	"/api/data/doc/revision/restore": common.ScopeDocWrite,
+	"/api/data/doc/tag/add":          common.ScopeDocWrite,
+	"/api/data/doc/tag/remove":       common.ScopeDocWrite,
+	"/api/data/tag/add":              common.ScopeDocWrite,
+	"/api/data/tag/rename":           common.ScopeDocWrite,
+	"/api/data/tag/delete":           common.ScopeDocWrite,
+	"/api/data/pic/upload":           common.ScopePictureUpload,
+	"/api/data/doc/share/add":        common.ScopePublish,
+	"/api/data/doc/share/list":       common.ScopePublish,
+	"/api/data/doc/share/revoke":     common.ScopePublish,
+	"/api/data/doc/share/delete":     common.ScopePublish,
+}
+
+// t_api_token表中认证需要的字段
+type apiTokenRecord struct {
+	Id           string `db:"id"`
+	Scopes       string `db:"scopes"`
+	ExpireTime   int64  `db:"expire_time"`
+	LastUsedTime int64  `db:"last_used_time"`
+	UserId       string `db:"user_id"`
+}
+
+// 个人访问令牌认证，校验令牌有效、用户未禁用，且令牌具有当前接口所需的权限
+func apiTokenAuth(ctx iris.Context, token string) {
+	record := apiTokenRecord{}
+	sql := `select t.id,t.scopes,t.expire_time,t.last_used_time,t.user_id from t_api_token t join t_user u on u.id=t.user_id where t.token_hash=$1 and u.disabled=$2`
+	err := Db.Get(&record, sql, TokenHash(token), false)
+	now := time.Now().UnixMilli()
+	if err != nil || (record.ExpireTime > 0 && record.ExpireTime <= now) {
+		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
+	}
+
+	scopes := strings.Split(record.Scopes, ",")
+	if !apiTokenScopeAllowed(ctx.Path(), scopes) {
+		panic(common.NewErrorCode(common.HttpForbidden, "令牌无此接口的访问权限"))
+	}
+
+	if now-record.LastUsedTime > apiTokenTouchInterval.Milliseconds() {
+		_, err = DbW.Exec(`update t_api_token set last_used_time=$1 where id=$2`, now, record.Id)
+		if err != nil {
+			Log.Error("更新令牌使用时间失败：", err)
+		}
+	}
+
+	ctx.Values().Set(currentUserIdKey, record.UserId)
+	ctx.Values().Set(apiTokenScopeKey, scopes)
+}
+
+// 判断令牌权限是否可以访问接口，未列出的接口均不可访问
+func apiTokenScopeAllowed(path string, scopes []string) bool {
+	scope, ok := apiTokenScopes[path]
+	return ok && slices.Contains(scopes, scope)
+}
+
+// 使用个人访问令牌时校验令牌具有指定权限，登录token拥有全部权限
+func CheckScope(ctx iris.Context, scope string) {
+	scopes, ok := ctx.Values().Get(apiTokenScopeKey).([]string)
+	if ok && !slices.Contains(scopes, scope) {
+		panic(common.NewErrorCode(common.HttpForbidden, "令牌缺少权限："+scope))
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"md/model/common"
+	"testing"
+)
+
+func TestApiTokenScopeAllowed(t *testing.T) {
+	all := []string{common.ScopeDocRead, common.ScopeDocWrite, common.ScopePictureUpload, common.ScopePublish}
+	tests := []struct {
+		name    string
+		path    string
+		scopes  []string
+		allowed bool
+	}{
+		{"读取权限读取文档", "/api/data/doc/get", []string{common.ScopeDocRead}, true},
+		{"读取权限修改文档", "/api/data/doc/update", []string{common.ScopeDocRead}, false},
+		{"写入权限修改文档", "/api/data/doc/update", []string{common.ScopeDocRead, common.ScopeDocWrite}, true},
+		{"写入权限读取文档", "/api/data/doc/get", []string{common.ScopeDocWrite}, false},
+		{"上传图片", "/api/data/pic/upload", []string{common.ScopePictureUpload}, true},
+		{"无上传权限", "/api/data/pic/upload", []string{common.ScopeDocWrite}, false},
+		{"分享", "/api/data/doc/share/add", []string{common.ScopePublish}, true},
+		{"无权限", "/api/data/doc/get", []string{""}, false},
+		{"修改密码", "/api/data/user/update-password", all, false},
+		{"管理令牌", "/api/data/user/tokens/add", all, false},
+		{"备份", "/api/data/backup", all, false},
+		{"同步", "/api/admin/sync/apply", all, false},
+		{"回收站", "/api/data/trash/list", all, false},
+		{"管理接口", "/api/admin/user/list", all, false},
+		{"末尾斜杠", "/api/data/doc/get/", all, false},
+		{"大小写不同", "/api/data/Doc/get", all, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiTokenScopeAllowed(tt.path, tt.scopes); got != tt.allowed {
+				t.Errorf("apiTokenScopeAllowed(%q, %v) = %v，期望 %v", tt.path, tt.scopes, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestApiTokenScopes(t *testing.T) {
+	valid := map[string]bool{common.ScopeDocRead: true, common.ScopeDocWrite: true, common.ScopePictureUpload: true, common.ScopePublish: true}
+	for path, scope := range apiTokenScopes {
+		if !valid[scope] {
+			t.Errorf("接口 %s 的权限 %q 无效", path, scope)
+		}
+	}
+}
@@ -16,8 +16,8 @@ var Db *sqlx.DB
 // 数据库写连接
 var DbW *sqlx.DB
 
-// 建表语句
-var createTableSql = `
+// 第1个迁移的建表语句，即引入数据库迁移前的表结构，已发布不可修改，表结构变化需新增迁移
+const createTableSqlV1 = `
 CREATE TABLE IF NOT EXISTS t_user
 (
 	id varchar(50) PRIMARY KEY NOT NULL,
@@ -87,11 +87,32 @@ ON "t_user" (
 );
 `
 
-var deleteTableSql = `
-DELETE FROM t_user;
-DELETE FROM t_document;
-DELETE FROM t_book;
-DELETE FROM t_picture;
+// sqlite全文检索：trigram分词的fts5虚拟表，通过触发器与t_document同步
+const createSqliteSearchSql = `
+CREATE VIRTUAL TABLE IF NOT EXISTS t_document_fts USING fts5(id UNINDEXED, name, content, tokenize='trigram');
+
+CREATE TRIGGER IF NOT EXISTS document_fts_insert AFTER INSERT ON t_document BEGIN
+	INSERT INTO t_document_fts (id, name, content) VALUES (new.id, new.name, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS document_fts_update AFTER UPDATE OF name, content ON t_document BEGIN
+	UPDATE t_document_fts SET name = new.name, content = new.content WHERE id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS document_fts_delete AFTER DELETE ON t_document BEGIN
+	DELETE FROM t_document_fts WHERE id = old.id;
+END;
+
+INSERT INTO t_document_fts (id, name, content)
+SELECT id, name, content FROM t_document WHERE id NOT IN (SELECT id FROM t_document_fts);
+`
+
+// postgres全文检索：名称、内容加权的tsvector表达式GIN索引
+const createPostgresSearchSql = `
+CREATE INDEX IF NOT EXISTS "document_search"
+ON "t_document" USING GIN (
+  (setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', content), 'B'))
+);
 `
 
 // 初始化数据库连接
@@ -106,30 +127,42 @@ func InitDB() error {
 		return err
 	}
 
-	// 创建表结构
-	Db.MustExec(createTableSql)
-
-	if common.RefreshDb {
-		// 清空表
-		Db.MustExec(deleteTableSql)
-		// 创建表结构
-		Db.MustExec(createTableSql)
+	// 查看迁移状态时不执行迁移
+	if !common.MigrateStatus {
+		err = Migrate()
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// 关闭数据库连接
+func CloseDB() {
+	if DbW != nil && DbW != Db {
+		DbW.Close()
+	}
+	if Db != nil {
+		Db.Close()
+	}
+}
+
+// sqlite数据库文件路径
+func SqlitePath() string {
+	return filepath.Join(common.DataPath, "md.db")
+}
+
 // 初始化sqlite
 func initSqlite() error {
-	// 开启数据库文件
 	var err error
-	Db, err = sqlx.Connect("sqlite", filepath.Join(common.DataPath, "md.db"))
+	Db, err = OpenSqlite()
 	if err != nil {
 		Log.Error("开启sqlite数据库文件失败：", err)
 		return err
 	}
 
-	DbW, err = sqlx.Connect("sqlite", filepath.Join(common.DataPath, "md.db"))
+	DbW, err = OpenSqlite()
 	if err != nil {
 		Log.Error("开启sqlite数据库文件失败：", err)
 		return err
@@ -143,7 +176,7 @@ func initSqlite() error {
 // 初始化postgres
 func initPostgres() error {
 	var err error
-	Db, err = sqlx.Connect("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", common.PostgresHost, common.PostgresPort, common.PostgresUser, common.PostgresPassword, common.PostgresDB))
+	Db, err = OpenPostgres()
 	if err != nil {
 		Log.Error("postgres连接失败：", err)
 		return err
@@ -154,3 +187,14 @@ func initPostgres() error {
 	Log.Info("成功连接postgres")
 	return nil
 }
+
+// OpenSqlite 开启数据目录中的sqlite数据库文件
+func OpenSqlite() (*sqlx.DB, error) {
+	// 设置锁等待时间，避免读写并发时直接返回database is locked
+	return sqlx.Connect("sqlite", SqlitePath()+"?_pragma=busy_timeout(5000)")
+}
+
+// OpenPostgres 按命令行参数连接postgres
+func OpenPostgres() (*sqlx.DB, error) {
+	return sqlx.Connect("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", common.PostgresHost, common.PostgresPort, common.PostgresUser, common.PostgresPassword, common.PostgresDB))
+}
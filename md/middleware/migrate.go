@@ -0,0 +1,537 @@
+package middleware
+
+import (
+	"fmt"
+	"md/model/common"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 数据库迁移，按版本号顺序执行，每个迁移只执行一次
+type migration struct {
+	version  int
+	name     string
+	sqlite   string                  // sqlite执行的语句
+	postgres string                  // postgres执行的语句
+	run      func(tx *sqlx.Tx) error // 语句之后执行的函数，可为空
+}
+
+// 迁移状态
+type MigrationStatus struct {
+	Version     int    `db:"version"`
+	Name        string `db:"name"`
+	AppliedTime int64  `db:"applied_time"` // 执行时间，为0时未执行
+}
+
+// 全部迁移，新增迁移追加到末尾，已发布的迁移不可修改
+var migrations = []migration{
+	{
+		version:  1,
+		name:     "初始表结构",
+		sqlite:   createTableSqlV1,
+		postgres: createTableSqlV1,
+	},
+	{
+		version:  2,
+		name:     "登录token",
+		sqlite:   createTokenSql,
+		postgres: createTokenSql,
+	},
+	{
+		version:  3,
+		name:     "文档历史版本",
+		sqlite:   createDocumentRevisionSql,
+		postgres: createDocumentRevisionSql,
+	},
+	{
+		version:  4,
+		name:     "全文检索索引",
+		sqlite:   createSqliteSearchSql,
+		postgres: createPostgresSearchSql,
+	},
+	{
+		version:  5,
+		name:     "图片路径索引",
+		sqlite:   createPicturePathSql,
+		postgres: createPicturePathSql,
+	},
+	{
+		version:  6,
+		name:     "同步记录",
+		sqlite:   createSyncSql,
+		postgres: createSyncSql,
+	},
+	{
+		version:  7,
+		name:     "文档分享",
+		sqlite:   createDocumentShareSql,
+		postgres: createDocumentShareSql,
+	},
+	{
+		version:  8,
+		name:     "文档标签",
+		sqlite:   createTagSql,
+		postgres: createTagSql,
+	},
+	{
+		version: 9,
+		name:    "排序序号",
+		run: addColumns(
+			tableColumn{"t_book", "sort_index", "bigint NOT NULL DEFAULT 0"},
+			tableColumn{"t_document", "sort_index", "bigint NOT NULL DEFAULT 0"},
+		),
+	},
+	{
+		version: 10,
+		name:    "回收站",
+		run: addColumns(
+			tableColumn{"t_book", "deleted_time", "bigint NOT NULL DEFAULT 0"},
+			tableColumn{"t_document", "deleted_time", "bigint NOT NULL DEFAULT 0"},
+			tableColumn{"t_picture", "deleted_time", "bigint NOT NULL DEFAULT 0"},
+		),
+	},
+	{
+		version: 11,
+		name:    "回收站索引",
+		sqlite: `
+CREATE INDEX IF NOT EXISTS "document_deleted_time" ON "t_document" ("deleted_time" ASC);
+CREATE INDEX IF NOT EXISTS "book_deleted_time" ON "t_book" ("deleted_time" ASC);
+CREATE INDEX IF NOT EXISTS "picture_deleted_time" ON "t_picture" ("deleted_time" ASC);
+`,
+		postgres: `
+CREATE INDEX IF NOT EXISTS "document_deleted_time" ON "t_document" ("deleted_time" ASC) WHERE deleted_time > 0;
+CREATE INDEX IF NOT EXISTS "book_deleted_time" ON "t_book" ("deleted_time" ASC) WHERE deleted_time > 0;
+CREATE INDEX IF NOT EXISTS "picture_deleted_time" ON "t_picture" ("deleted_time" ASC) WHERE deleted_time > 0;
+`,
+	},
+	{
+		version:  12,
+		name:     "用户角色",
+		sqlite:   addUserRoleSql,
+		postgres: addUserRoleSql,
+		run:      migrateUserRole,
+	},
+	{
+		version:  13,
+		name:     "登录失败记录",
+		sqlite:   createSignInAttemptSql,
+		postgres: createSignInAttemptSql,
+	},
+	{
+		version:  14,
+		name:     "两步验证",
+		sqlite:   addTotpSql,
+		postgres: addTotpSql,
+	},
+	{
+		version:  15,
+		name:     "个人访问令牌",
+		sqlite:   createApiTokenSql,
+		postgres: createApiTokenSql,
+	},
+	{
+		version: 16,
+		name:    "登录token哈希",
+		run:     migrateTokenHash,
+	},
+	{
+		version:  17,
+		name:     "文档版本号",
+		sqlite:   addDocumentVersionSql,
+		postgres: addDocumentVersionSql,
+	},
+	{
+		version: 18,
+		name:    "全文检索三元组索引",
+		run:     migratePostgresTrigram,
+	},
+}
+
+const createTokenSql = `
+CREATE TABLE IF NOT EXISTS t_token
+(
+	token varchar(100) PRIMARY KEY NOT NULL,
+	type varchar(20) NOT NULL,
+	user_id varchar(50) NOT NULL,
+	name text NOT NULL,
+	access_token varchar(100) NOT NULL,
+	refresh_token varchar(100) NOT NULL,
+	expire_time bigint NOT NULL,
+	create_time bigint NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "token_expire_time"
+ON "t_token" (
+  "expire_time" ASC
+);
+`
+
+const createDocumentRevisionSql = `
+CREATE TABLE IF NOT EXISTS t_document_revision
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	document_id varchar(50) NOT NULL,
+	content text NOT NULL,
+	create_time bigint NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "document_revision_document_id"
+ON "t_document_revision" (
+  "document_id" ASC,
+  "create_time" DESC
+);
+`
+
+const createPicturePathSql = `
+CREATE INDEX IF NOT EXISTS "picture_path"
+ON "t_picture" (
+  "path" ASC
+);
+`
+
+const createSyncSql = `
+CREATE TABLE IF NOT EXISTS t_sync
+(
+	user_id varchar(50) PRIMARY KEY NOT NULL,
+	sync_time bigint NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS t_sync_path
+(
+	user_id varchar(50) NOT NULL,
+	path text NOT NULL,
+	PRIMARY KEY (user_id, path)
+);
+`
+
+const createDocumentShareSql = `
+CREATE TABLE IF NOT EXISTS t_document_share
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	token varchar(100) NOT NULL UNIQUE,
+	document_id varchar(50) NOT NULL,
+	password text NOT NULL,
+	expire_time bigint NOT NULL,
+	view_count bigint NOT NULL,
+	revoked boolean NOT NULL,
+	create_time bigint NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "document_share_document_id"
+ON "t_document_share" (
+  "document_id" ASC
+);
+`
+
+const createTagSql = `
+CREATE TABLE IF NOT EXISTS t_tag
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	name text NOT NULL,
+	create_time bigint NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS t_document_tag
+(
+	document_id varchar(50) NOT NULL,
+	tag_id varchar(50) NOT NULL,
+	PRIMARY KEY (document_id, tag_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "tag_user_id_name"
+ON "t_tag" (
+  "user_id" ASC,
+  "name" ASC
+);
+
+CREATE INDEX IF NOT EXISTS "document_tag_tag_id"
+ON "t_document_tag" (
+  "tag_id" ASC
+);
+`
+
+const addDocumentVersionSql = `
+ALTER TABLE t_document ADD COLUMN version bigint NOT NULL DEFAULT 1;
+`
+
+// postgres检索中未分词文本的ilike匹配使用三元组GIN索引，sqlite的fts5已包含相同功能
+const createPostgresTrigramSql = `
+CREATE INDEX IF NOT EXISTS "document_name_trgm" ON "t_document" USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS "document_content_trgm" ON "t_document" USING GIN (content gin_trgm_ops);
+`
+
+const createSignInAttemptSql = `
+CREATE TABLE IF NOT EXISTS t_sign_in_attempt
+(
+	type varchar(20) NOT NULL,
+	name text NOT NULL,
+	failed_count bigint NOT NULL,
+	last_failed_time bigint NOT NULL,
+	locked_until bigint NOT NULL,
+	PRIMARY KEY (type, name)
+);
+`
+
+const addTotpSql = `
+ALTER TABLE t_user ADD COLUMN totp_secret text NOT NULL DEFAULT '';
+ALTER TABLE t_user ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false;
+ALTER TABLE t_user ADD COLUMN totp_last_step bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS t_recovery_code
+(
+	user_id varchar(50) NOT NULL,
+	code varchar(100) NOT NULL,
+	create_time bigint NOT NULL,
+	PRIMARY KEY (user_id, code)
+);
+`
+
+const createApiTokenSql = `
+CREATE TABLE IF NOT EXISTS t_api_token
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	name text NOT NULL,
+	token_hash varchar(100) NOT NULL UNIQUE,
+	token_prefix varchar(20) NOT NULL,
+	scopes text NOT NULL,
+	expire_time bigint NOT NULL,
+	last_used_time bigint NOT NULL,
+	create_time bigint NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "api_token_user_id"
+ON "t_api_token" (
+  "user_id" ASC
+);
+`
+
+const addUserRoleSql = `
+ALTER TABLE t_user ADD COLUMN role varchar(20) NOT NULL DEFAULT 'user';
+ALTER TABLE t_user ADD COLUMN disabled boolean NOT NULL DEFAULT false;
+`
+
+const createSchemaVersionSql = `
+CREATE TABLE IF NOT EXISTS t_schema_version
+(
+	version integer PRIMARY KEY NOT NULL,
+	name text NOT NULL,
+	applied_time bigint NOT NULL
+);
+`
+
+// Migrate 执行全部未执行的迁移
+func Migrate() error {
+	return MigrateDb(DbW)
+}
+
+// MigrateDb 在指定的数据库连接上执行全部未执行的迁移
+func MigrateDb(db *sqlx.DB) error {
+	applied, err := appliedMigrations(db, true)
+	if err != nil {
+		Log.Error("查询数据库版本失败：", err)
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		err = runMigration(db, m)
+		if err != nil {
+			Log.Errorf("数据库迁移失败: {%d %s} %s", m.version, m.name, err)
+			return err
+		}
+		Log.Infof("数据库迁移: {%d %s}", m.version, m.name)
+	}
+	return nil
+}
+
+// SchemaVersion 当前程序的数据库版本，即最后一个迁移的版本号
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// MigrationStatusList 查询全部迁移的执行状态
+func MigrationStatusList() ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(Db, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []MigrationStatus{}
+	for _, m := range migrations {
+		result = append(result, MigrationStatus{Version: m.version, Name: m.name, AppliedTime: applied[m.version]})
+	}
+	return result, nil
+}
+
+// 查询已执行的迁移版本及执行时间，create为false时不创建版本表，用于只读查询迁移状态
+func appliedMigrations(db *sqlx.DB, create bool) (map[int]int64, error) {
+	applied := map[int]int64{}
+	if create {
+		if _, err := db.Exec(createSchemaVersionSql); err != nil {
+			return nil, err
+		}
+	} else {
+		exists, err := schemaVersionExists(db)
+		if err != nil || !exists {
+			return applied, err
+		}
+	}
+
+	versions := []MigrationStatus{}
+	err := db.Select(&versions, `select version,name,applied_time from t_schema_version`)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range versions {
+		applied[v.Version] = v.AppliedTime
+	}
+	return applied, nil
+}
+
+// 查询版本表是否存在
+func schemaVersionExists(db *sqlx.DB) (bool, error) {
+	sql := `select count(*) from sqlite_master where type='table' and name='t_schema_version'`
+	if db.DriverName() == "postgres" {
+		sql = `select count(*) from information_schema.tables where table_schema=current_schema() and table_name='t_schema_version'`
+	}
+	var count int
+	err := db.Get(&count, sql)
+	return count > 0, err
+}
+
+// 在一个事务中执行迁移并记录版本
+func runMigration(db *sqlx.DB, m migration) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sql := m.sqlite
+	if tx.DriverName() == "postgres" {
+		sql = m.postgres
+	}
+	if sql != "" {
+		if _, err = tx.Exec(sql); err != nil {
+			return err
+		}
+	}
+	if m.run != nil {
+		if err = m.run(tx); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`insert into t_schema_version (version,name,applied_time) values ($1,$2,$3)`, m.version, m.name, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// 表中新增的列
+type tableColumn struct {
+	table      string
+	column     string
+	definition string
+}
+
+// 添加不存在的列，sqlite不支持 ADD COLUMN IF NOT EXISTS，
+// 引入数据库迁移前通过启动时补充列的方式已添加过的列在此跳过
+func addColumns(columns ...tableColumn) func(tx *sqlx.Tx) error {
+	return func(tx *sqlx.Tx) error {
+		for _, c := range columns {
+			exists, err := columnExists(tx, c.table, c.column)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			if _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+				return err
+			}
+			Log.Infof("成功添加列: {%s.%s}", c.table, c.column)
+		}
+		return nil
+	}
+}
+
+// 已有用户中名为admin的用户设为管理员，没有时最早注册的用户设为管理员
+func migrateUserRole(tx *sqlx.Tx) error {
+	_, err := tx.Exec(`update t_user set role=$1 where name='admin'`, common.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.Get(&count, `select count(*) from t_user where role=$1`, common.RoleAdmin)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = tx.Exec(`update t_user set role=$1 where id=(select id from t_user order by create_time limit 1)`, common.RoleAdmin)
+	return err
+}
+
+// 已保存的登录token改为只保存哈希，已登录的用户无需重新登录
+func migrateTokenHash(tx *sqlx.Tx) error {
+	records := []tokenRecord{}
+	err := tx.Select(&records, `select * from t_token`)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		_, err = tx.Exec(`update t_token set token=$1,access_token=$2,refresh_token=$3 where token=$4`,
+			TokenHash(r.Token), TokenHash(r.AccessToken), TokenHash(r.RefreshToken), r.Token)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postgres创建pg_trgm扩展及三元组索引，托管数据库或非超级用户无法创建扩展时跳过索引，检索仍可使用但ilike匹配需全表扫描
+func migratePostgresTrigram(tx *sqlx.Tx) error {
+	if tx.DriverName() != "postgres" {
+		return nil
+	}
+
+	var count int
+	err := tx.Get(&count, `select count(*) from pg_extension where extname='pg_trgm'`)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		// 创建失败会中止事务，使用保存点回滚失败的语句
+		if _, err = tx.Exec(`SAVEPOINT create_pg_trgm`); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`); err != nil {
+			Log.Warnf("创建pg_trgm扩展失败，跳过全文检索三元组索引，可由超级用户创建扩展后手动创建索引: %s", err)
+			_, err = tx.Exec(`ROLLBACK TO SAVEPOINT create_pg_trgm`)
+			return err
+		}
+	}
+
+	_, err = tx.Exec(createPostgresTrigramSql)
+	return err
+}
+
+// 查询表中是否存在指定列
+func columnExists(tx *sqlx.Tx, table, column string) (bool, error) {
+	sql := `select count(*) from pragma_table_info($1) where name=$2`
+	if tx.DriverName() == "postgres" {
+		sql = `select count(*) from information_schema.columns where table_schema=current_schema() and table_name=$1 and column_name=$2`
+	}
+	var count int
+	err := tx.Get(&count, sql, table, column)
+	return count > 0, err
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"md/model/common"
+	"time"
+
+	"github.com/muesli/cache2go"
+)
+
+const (
+	TokenStoreMemory = "memory" // token存储方式：内存
+	TokenStoreDb     = "db"     // token存储方式：数据库
+)
+
+// token存储
+var Tokens TokenStore
+
+// token存储接口，cacheName为 common.AccessTokenCache、common.RefreshTokenCache 或 common.TotpTokenCache
+type TokenStore interface {
+	// 保存token
+	Save(cacheName, token string, expire time.Duration, tokenCache *common.TokenCache) error
+	// 查询token，不存在或已过期时返回error
+	Get(cacheName, token string) (*common.TokenCache, error)
+	// 删除token
+	Delete(cacheName, token string) error
+	// 删除Get查询到的一次登录的AccessToken和RefreshToken
+	DeleteSession(tokenCache *common.TokenCache) error
+	// 删除用户的全部token
+	DeleteByUser(userId string) error
+}
+
+// 初始化token存储，需在数据库初始化之后执行
+func InitTokenStore(storeType string) error {
+	switch storeType {
+	case TokenStoreMemory:
+		Tokens = &memoryTokenStore{}
+	case TokenStoreDb:
+		Tokens = &dbTokenStore{}
+		// 定时清理过期token
+		ticker := time.NewTicker(time.Hour)
+		go func() {
+			for {
+				<-ticker.C
+				_, err := DbW.Exec(`delete from t_token where expire_time<$1`, time.Now().UnixMilli())
+				if err != nil {
+					Log.Error("清理过期token失败：", err)
+				}
+			}
+		}()
+	default:
+		err := errors.New("不支持的token存储方式：" + storeType)
+		Log.Error(err)
+		return err
+	}
+
+	Log.Infof("token存储方式: {%s}", storeType)
+	return nil
+}
+
+// 内存token存储，服务重启后失效
+type memoryTokenStore struct{}
+
+func (s *memoryTokenStore) Save(cacheName, token string, expire time.Duration, tokenCache *common.TokenCache) error {
+	cache2go.Cache(cacheName).Add(token, expire, tokenCache)
+	return nil
+}
+
+func (s *memoryTokenStore) Get(cacheName, token string) (*common.TokenCache, error) {
+	res, err := cache2go.Cache(cacheName).Value(token)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data().(*common.TokenCache), nil
+}
+
+func (s *memoryTokenStore) Delete(cacheName, token string) error {
+	_, err := cache2go.Cache(cacheName).Delete(token)
+	return err
+}
+
+func (s *memoryTokenStore) DeleteSession(tokenCache *common.TokenCache) error {
+	cache2go.Cache(common.AccessTokenCache).Delete(tokenCache.AccessToken)
+	cache2go.Cache(common.RefreshTokenCache).Delete(tokenCache.RefreshToken)
+	return nil
+}
+
+func (s *memoryTokenStore) DeleteByUser(userId string) error {
+	for _, cacheName := range []string{common.AccessTokenCache, common.RefreshTokenCache, common.TotpTokenCache} {
+		cache := cache2go.Cache(cacheName)
+		// 遍历时持有读锁，先收集再删除
+		tokens := []interface{}{}
+		cache.Foreach(func(key interface{}, item *cache2go.CacheItem) {
+			if item.Data().(*common.TokenCache).Id == userId {
+				tokens = append(tokens, key)
+			}
+		})
+		for _, token := range tokens {
+			cache.Delete(token)
+		}
+	}
+	return nil
+}
+
+// 数据库token存储，保存在t_token表中，表中只保存token的哈希，
+// Get返回的AccessToken、RefreshToken也是哈希，只能用于DeleteSession
+type dbTokenStore struct{}
+
+// t_token表记录
+type tokenRecord struct {
+	Token        string `db:"token"`
+	Type         string `db:"type"`
+	UserId       string `db:"user_id"`
+	Name         string `db:"name"`
+	AccessToken  string `db:"access_token"`
+	RefreshToken string `db:"refresh_token"`
+	ExpireTime   int64  `db:"expire_time"`
+	CreateTime   int64  `db:"create_time"`
+}
+
+func (s *dbTokenStore) Save(cacheName, token string, expire time.Duration, tokenCache *common.TokenCache) error {
+	now := time.Now()
+	record := tokenRecord{
+		Token:        TokenHash(token),
+		Type:         cacheName,
+		UserId:       tokenCache.Id,
+		Name:         tokenCache.Name,
+		AccessToken:  TokenHash(tokenCache.AccessToken),
+		RefreshToken: TokenHash(tokenCache.RefreshToken),
+		ExpireTime:   now.Add(expire).UnixMilli(),
+		CreateTime:   now.UnixMilli(),
+	}
+	sql := `insert into t_token (token,type,user_id,name,access_token,refresh_token,expire_time,create_time) values (:token,:type,:user_id,:name,:access_token,:refresh_token,:expire_time,:create_time)`
+	_, err := DbW.NamedExec(sql, record)
+	return err
+}
+
+func (s *dbTokenStore) Get(cacheName, token string) (*common.TokenCache, error) {
+	sql := `select * from t_token where token=$1 and type=$2 and expire_time>$3`
+	record := tokenRecord{}
+	err := Db.Get(&record, sql, TokenHash(token), cacheName, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	tokenCache := common.TokenCache{}
+	tokenCache.Id = record.UserId
+	tokenCache.Name = record.Name
+	tokenCache.AccessToken = record.AccessToken
+	tokenCache.RefreshToken = record.RefreshToken
+	return &tokenCache, nil
+}
+
+func (s *dbTokenStore) Delete(cacheName, token string) error {
+	sql := `delete from t_token where token=$1 and type=$2`
+	_, err := DbW.Exec(sql, TokenHash(token), cacheName)
+	return err
+}
+
+func (s *dbTokenStore) DeleteSession(tokenCache *common.TokenCache) error {
+	sql := `delete from t_token where (token=$1 and type=$2) or (token=$3 and type=$4)`
+	_, err := DbW.Exec(sql, tokenCache.AccessToken, common.AccessTokenCache, tokenCache.RefreshToken, common.RefreshTokenCache)
+	return err
+}
+
+func (s *dbTokenStore) DeleteByUser(userId string) error {
+	sql := `delete from t_token where user_id=$1`
+	_, err := DbW.Exec(sql, userId)
+	return err
+}
+
+// token的sha256哈希，数据库中只保存哈希
+func TokenHash(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -23,6 +23,8 @@ type TokenResult struct {
 	Name         string `json:"name"`
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
+	TotpRequired bool   `json:"totpRequired,omitempty"` // 已开启两步验证，需使用TotpToken和验证码完成登录
+	TotpToken    string `json:"totpToken,omitempty"`    // 两步验证的临时凭证
 }
 
 type TokenCache struct {
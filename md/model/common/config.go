@@ -14,5 +14,12 @@ var (
 	PostgresUser     string // postgres用户
 	PostgresPassword string // postgres密码
 	PostgresDB       string // postgres数据库名
-	RefreshDb        bool   // 刷新数据库数据
+	TokenStore       string // token存储方式：memory / db
+	RevisionKeep     int    // 每个文档保留的历史版本数量，小于等于0时不限制
+	Sync             string // 命令行同步模式：plan / apply，为空时启动服务
+	SyncUser         string // 命令行同步的用户名
+	TrashName        string // 回收站目录名，在数据目录下
+	TrashDays        int    // 回收站保留天数，小于等于0时不自动清理
+	MigrateOnly      bool   // 执行数据库迁移后退出
+	MigrateStatus    bool   // 输出数据库迁移状态后退出，不执行迁移
 )
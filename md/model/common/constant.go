@@ -3,5 +3,22 @@ package common
 const (
 	AccessTokenCache  = "AccessToken"  // 缓存：AccessToken
 	RefreshTokenCache = "RefreshToken" // 缓存：RefreshToken
-	SignInTimesCache  = "SignInTimes"  // 缓存：登录次数
+	TotpTokenCache    = "TotpToken"    // 缓存：两步验证临时凭证
+)
+
+// 用户角色
+const (
+	RoleAdmin = "admin" // 管理员
+	RoleUser  = "user"  // 普通用户
+)
+
+// 个人访问令牌的前缀，用于与登录token区分
+const ApiTokenPrefix = "mdp_"
+
+// 个人访问令牌的权限
+const (
+	ScopeDocRead       = "doc:read"       // 读取目录、文档、标签、图片列表
+	ScopeDocWrite      = "doc:write"      // 添加、修改、删除目录、文档、标签，导入
+	ScopePictureUpload = "picture:upload" // 上传图片
+	ScopePublish       = "publish"        // 公开发布文档、管理分享链接
 )
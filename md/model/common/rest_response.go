@@ -3,6 +3,9 @@ package common
 const (
 	HttpSuccess     = 200 // 请求成功
 	HttpAuthFailure = 401 // 认证失败
+	HttpForbidden   = 403 // 无访问权限，如缺少或错误的分享密码
+	HttpConflict    = 409 // 数据冲突，已被其他请求修改
+	HttpTooMany     = 429 // 请求过于频繁，如登录失败次数过多
 	HttpFailure     = 500 // 请求失败
 )
 
@@ -15,9 +18,10 @@ type RestResponse struct {
 
 // 主动抛出异常结构体
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Err     error  `json:"-"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+	Err     error       `json:"-"`
 }
 
 // 填写成功信息
@@ -53,6 +57,15 @@ func NewErrorCode(code int, message string) ErrorResponse {
 	}
 }
 
+// 填写错误编号、错误信息、数据
+func NewErrorData(code int, message string, data interface{}) ErrorResponse {
+	return ErrorResponse{
+		Code:    code,
+		Message: message,
+		Data:    data,
+	}
+}
+
 // 填写错误信息、error对象
 func NewErr(message string, err error) ErrorResponse {
 	return ErrorResponse{
@@ -0,0 +1,23 @@
+package entity
+
+// 个人访问令牌
+type ApiToken struct {
+	Id           string   `json:"id" db:"id"`
+	Name         string   `json:"name" db:"name"`
+	TokenHash    string   `json:"-" db:"token_hash"`
+	TokenPrefix  string   `json:"tokenPrefix" db:"token_prefix"` // 令牌开头的几位，便于识别
+	Scopes       string   `json:"-" db:"scopes"`                 // 逗号分隔的权限
+	ScopeList    []string `json:"scopes" db:"-"`
+	ExpireTime   int64    `json:"expireTime" db:"expire_time"`      // 为0时永不过期
+	LastUsedTime int64    `json:"lastUsedTime" db:"last_used_time"` // 为0时未使用过
+	CreateTime   int64    `json:"createTime" db:"create_time"`
+	UserId       string   `json:"userId" db:"user_id"`
+	Token        string   `json:"token,omitempty" db:"-"` // 令牌明文，仅在创建时返回
+}
+
+type ApiTokenCondition struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	ExpireTime int64    `json:"expireTime"` // 为0时永不过期
+}
@@ -0,0 +1,10 @@
+package entity
+
+// 备份文件清单
+type BackupManifest struct {
+	Format        int            `json:"format"`        // 备份文件格式版本
+	Driver        string         `json:"driver"`        // 备份时的数据库类型：sqlite / postgres
+	SchemaVersion int            `json:"schemaVersion"` // 备份时的数据库版本
+	CreateTime    int64          `json:"createTime"`
+	Tables        map[string]int `json:"tables"` // 逻辑备份的表及行数，sqlite备份为空
+}
@@ -1,9 +1,22 @@
 package entity
 
 type Book struct {
-	Id         string `json:"id" db:"id"`
-	ParentId   string `json:"parentId" db:"parent_id"`
-	Name       string `json:"name" db:"name"`
-	CreateTime int64  `json:"createTime" db:"create_time"`
-	UserId     string `json:"userId" db:"user_id"`
+	Id          string `json:"id" db:"id"`
+	ParentId    string `json:"parentId" db:"parent_id"`
+	Name        string `json:"name" db:"name"`
+	CreateTime  int64  `json:"createTime" db:"create_time"`
+	UserId      string `json:"userId" db:"user_id"`
+	SortIndex   int64  `json:"sortIndex" db:"sort_index"`     // 排序序号，相同时按名称排序
+	DeletedTime int64  `json:"deletedTime" db:"deleted_time"` // 移入回收站的时间，为0时未删除
+}
+
+// 目录树节点
+type BookTree struct {
+	Book
+	Children []BookTree `json:"children"`
+}
+
+// 调整顺序的参数
+type ReorderCondition struct {
+	Ids []string `json:"ids"` // 按新顺序排列的id
 }
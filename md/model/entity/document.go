@@ -1,15 +1,20 @@
 package entity
 
 type Document struct {
-	Id         string       `json:"id" db:"id"`
-	Name       string       `json:"name" db:"name"`
-	Content    string       `json:"content" db:"content"`
-	Type       DocumentType `json:"type" db:"type"`
-	Published  bool         `json:"published" db:"published"`
-	CreateTime int64        `json:"createTime" db:"create_time"`
-	UpdateTime int64        `json:"updateTime" db:"update_time"`
-	BookId     string       `json:"bookId" db:"book_id"`
-	UserId     string       `json:"userId" db:"user_id"`
+	Id          string       `json:"id" db:"id"`
+	Name        string       `json:"name" db:"name"`
+	Content     string       `json:"content" db:"content"`
+	Type        DocumentType `json:"type" db:"type"`
+	Published   bool         `json:"published" db:"published"`
+	CreateTime  int64        `json:"createTime" db:"create_time"`
+	UpdateTime  int64        `json:"updateTime" db:"update_time"`
+	BookId      string       `json:"bookId" db:"book_id"`
+	UserId      string       `json:"userId" db:"user_id"`
+	SortIndex   int64        `json:"sortIndex" db:"sort_index"`     // 排序序号，相同时按名称排序
+	DeletedTime int64        `json:"deletedTime" db:"deleted_time"` // 移入回收站的时间，为0时未删除
+	Version     int64        `json:"version" db:"version"`          // 内容版本号，从1开始，每次修改内容加1
+	// 保存内容时客户端读取到的版本号，与当前版本号不一致时拒绝保存，为0时不检查（仅用于恢复历史版本等服务端内部修改）
+	ExpectedVersion int64 `json:"expectedVersion" db:"-"`
 }
 
 type DocumentPageResult struct {
@@ -19,6 +24,7 @@ type DocumentPageResult struct {
 	CreateTime int64        `json:"createTime" db:"create_time"`
 	UpdateTime int64        `json:"updateTime" db:"update_time"`
 	Username   string       `json:"username" db:"username"`
+	BookId     string       `json:"bookId" db:"book_id"`
 	BookName   string       `json:"bookName" db:"book_name"`
 }
 
@@ -27,8 +33,67 @@ type DocumentPageCondition struct {
 	Name     string       `json:"name"`
 	Type     DocumentType `json:"type"`
 	BookName string       `json:"bookName"`
+	Tag      string       `json:"tag"` // 标签名称
+}
+
+type DocumentListCondition struct {
+	BookId string   `json:"bookId"`
+	TagIds []string `json:"tagIds"` // 包含全部标签的文档
+}
+
+type DocumentSearchCondition struct {
+	Keyword string   `json:"keyword"`
+	TagIds  []string `json:"tagIds"` // 包含全部标签的文档
+}
+
+type DocumentSearchResult struct {
+	Id            string  `json:"id" db:"id"`
+	Name          string  `json:"name" db:"name"`
+	Content       string  `json:"-" db:"content"`        // 关键字附近截取的部分内容
+	SnippetStart  int     `json:"-" db:"snippet_start"`  // 截取内容在全文中的起始位置，从1开始
+	ContentLength int     `json:"-" db:"content_length"` // 全文字符数
+	BookId        string  `json:"bookId" db:"book_id"`
+	BookName      string  `json:"bookName" db:"book_name"`
+	UpdateTime    int64   `json:"updateTime" db:"update_time"`
+	Score         float64 `json:"score" db:"score"`
+	NameHighlight string  `json:"nameHighlight"`
+	Snippet       string  `json:"snippet"`
 }
 
+type DocumentHtml struct {
+	Id         string            `json:"id"`
+	Name       string            `json:"name"`
+	Html       string            `json:"html"`
+	Toc        []DocumentHeading `json:"toc"`
+	UpdateTime int64             `json:"updateTime"`
+}
+
+type DocumentHeading struct {
+	Level int    `json:"level"`
+	Id    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+type SiteExportCondition struct {
+	Url string `json:"url"` // 站点部署地址，用于sitemap.xml和rss.xml中的绝对链接
+}
+
+type ImportResult struct {
+	Path       string       `json:"path"`
+	Status     ImportStatus `json:"status"`
+	Message    string       `json:"message"`
+	DocumentId string       `json:"documentId"`
+}
+
+type ImportStatus string
+
+const (
+	ImportCreated  ImportStatus = "created"  // 导入结果：已创建
+	ImportSkipped  ImportStatus = "skipped"  // 导入结果：已存在相同文档，跳过
+	ImportConflict ImportStatus = "conflict" // 导入结果：已存在同名但内容不同的文档或目录
+	ImportFailed   ImportStatus = "failed"   // 导入结果：失败
+)
+
 type DocumentType string
 
 const (
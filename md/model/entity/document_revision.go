@@ -0,0 +1,22 @@
+package entity
+
+type DocumentRevision struct {
+	Id         string `json:"id" db:"id"`
+	DocumentId string `json:"documentId" db:"document_id"`
+	Content    string `json:"content" db:"content"`
+	CreateTime int64  `json:"createTime" db:"create_time"`
+	UserId     string `json:"userId" db:"user_id"`
+}
+
+type DocumentRevisionCondition struct {
+	Id         string `json:"id"`
+	DocumentId string `json:"documentId"`
+	FromId     string `json:"fromId"` // 为空时使用文档当前内容
+	ToId       string `json:"toId"`   // 为空时使用文档当前内容
+}
+
+type DocumentRevisionDiff struct {
+	Diff    string `json:"diff"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
@@ -0,0 +1,29 @@
+package entity
+
+type DocumentShare struct {
+	Id          string `json:"id" db:"id"`
+	Token       string `json:"token" db:"token"`
+	DocumentId  string `json:"documentId" db:"document_id"`
+	Password    string `json:"-" db:"password"`
+	HasPassword bool   `json:"hasPassword" db:"-"`
+	ExpireTime  int64  `json:"expireTime" db:"expire_time"` // 为0时永不过期
+	ViewCount   int64  `json:"viewCount" db:"view_count"`
+	Revoked     bool   `json:"revoked" db:"revoked"`
+	CreateTime  int64  `json:"createTime" db:"create_time"`
+	UserId      string `json:"userId" db:"user_id"`
+}
+
+type DocumentShareCondition struct {
+	Id         string `json:"id"`
+	DocumentId string `json:"documentId"`
+	Password   string `json:"password"`   // 为空时无需密码
+	ExpireTime int64  `json:"expireTime"` // 为0时永不过期
+}
+
+type DocumentShareView struct {
+	Name       string       `json:"name"`
+	Content    string       `json:"content"`
+	Type       DocumentType `json:"type"`
+	UpdateTime int64        `json:"updateTime"`
+	ViewCount  int64        `json:"viewCount"`
+}
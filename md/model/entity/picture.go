@@ -1,13 +1,14 @@
 package entity
 
 type Picture struct {
-	Id         string `json:"id" db:"id"`
-	Name       string `json:"name" db:"name"`
-	Path       string `json:"path" db:"path"`
-	Hash       string `json:"hash" db:"hash"`
-	Size       int64  `json:"size" db:"size"`
-	CreateTime int64  `json:"createTime" db:"create_time"`
-	UserId     string `json:"userId" db:"user_id"`
+	Id          string `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Path        string `json:"path" db:"path"`
+	Hash        string `json:"hash" db:"hash"`
+	Size        int64  `json:"size" db:"size"`
+	CreateTime  int64  `json:"createTime" db:"create_time"`
+	UserId      string `json:"userId" db:"user_id"`
+	DeletedTime int64  `json:"deletedTime" db:"deleted_time"` // 移入回收站的时间，为0时未删除
 }
 
 type PicturePageResult struct {
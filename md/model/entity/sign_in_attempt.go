@@ -0,0 +1,25 @@
+package entity
+
+// 登录失败记录类型
+type SignInAttemptType string
+
+const (
+	SignInAttemptAccount SignInAttemptType = "account" // 按用户名
+	SignInAttemptIp      SignInAttemptType = "ip"      // 按客户端ip
+	SignInAttemptShare   SignInAttemptType = "share"   // 按客户端ip记录分享链接访问密码的失败
+)
+
+// 登录失败记录
+type SignInAttempt struct {
+	Type           SignInAttemptType `json:"type" db:"type"`
+	Name           string            `json:"name" db:"name"` // 用户名或ip
+	FailedCount    int64             `json:"failedCount" db:"failed_count"`
+	LastFailedTime int64             `json:"lastFailedTime" db:"last_failed_time"`
+	LockedUntil    int64             `json:"lockedUntil" db:"locked_until"` // 锁定截止时间，为0时未锁定
+}
+
+// 解除锁定的参数
+type SignInAttemptCondition struct {
+	Type SignInAttemptType `json:"type"`
+	Name string            `json:"name"`
+}
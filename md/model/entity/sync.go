@@ -0,0 +1,38 @@
+package entity
+
+type SyncCondition struct {
+	UserId string `json:"userId"` // 同步的用户id
+}
+
+type SyncReport struct {
+	DryRun       bool         `json:"dryRun"`
+	UserName     string       `json:"userName"`
+	LastSyncTime int64        `json:"lastSyncTime"`
+	SyncTime     int64        `json:"syncTime"`
+	Actions      []SyncAction `json:"actions"`
+}
+
+type SyncAction struct {
+	Action  SyncActionType `json:"action"`
+	Path    string         `json:"path"` // 数据目录下的相对路径
+	Id      string         `json:"id"`   // 目录或文档id
+	Reason  string         `json:"reason"`
+	Applied bool           `json:"applied"`
+	Error   string         `json:"error"`
+}
+
+type SyncActionType string
+
+const (
+	SyncCreateBook     SyncActionType = "create-book"     // 文件夹新增，创建目录
+	SyncDeleteBook     SyncActionType = "delete-book"     // 文件夹已删除，删除目录
+	SyncCreateDir      SyncActionType = "create-dir"      // 目录新增，创建文件夹
+	SyncDeleteDir      SyncActionType = "delete-dir"      // 目录已删除，删除文件夹
+	SyncCreateDocument SyncActionType = "create-document" // 文件新增，创建文档
+	SyncUpdateDocument SyncActionType = "update-document" // 文件较新，更新文档内容
+	SyncDeleteDocument SyncActionType = "delete-document" // 文件已删除，删除文档
+	SyncCreateFile     SyncActionType = "create-file"     // 文档新增，创建文件
+	SyncUpdateFile     SyncActionType = "update-file"     // 文档较新，更新文件内容
+	SyncDeleteFile     SyncActionType = "delete-file"     // 文档已删除，删除文件
+	SyncConflict       SyncActionType = "conflict"        // 无法自动处理，跳过
+)
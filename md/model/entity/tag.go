@@ -0,0 +1,15 @@
+package entity
+
+type Tag struct {
+	Id            string `json:"id" db:"id"`
+	Name          string `json:"name" db:"name"`
+	CreateTime    int64  `json:"createTime" db:"create_time"`
+	UserId        string `json:"userId" db:"user_id"`
+	DocumentCount int    `json:"documentCount" db:"document_count"` // 标签下的文档数量
+}
+
+type DocumentTagCondition struct {
+	DocumentId string `json:"documentId"`
+	TagId      string `json:"tagId"`
+	Name       string `json:"name"` // 添加标签时使用，不存在时自动创建
+}
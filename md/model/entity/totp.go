@@ -0,0 +1,20 @@
+package entity
+
+// 两步验证的参数
+type TotpCondition struct {
+	Password  string `json:"password"`
+	Code      string `json:"code"`      // 验证器应用生成的6位验证码或恢复码
+	TotpToken string `json:"totpToken"` // 登录第一步返回的临时凭证
+}
+
+// 开始设置两步验证的结果
+type TotpSetup struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"` // otpauth链接，用于生成二维码
+}
+
+// 两步验证状态
+type TotpStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodeCount int  `json:"recoveryCodeCount"` // 剩余可用的恢复码数量
+}
@@ -0,0 +1,24 @@
+package entity
+
+// 回收站条目类型
+type TrashType string
+
+const (
+	TrashDocument TrashType = "document"
+	TrashBook     TrashType = "book"
+	TrashPicture  TrashType = "picture"
+)
+
+// 回收站条目
+type TrashItem struct {
+	Id          string    `json:"id"`
+	Type        TrashType `json:"type"`
+	Name        string    `json:"name"`
+	DeletedTime int64     `json:"deletedTime"`
+}
+
+// 恢复、彻底删除回收站条目的参数
+type TrashCondition struct {
+	Id   string    `json:"id"`
+	Type TrashType `json:"type"`
+}
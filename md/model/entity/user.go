@@ -1,14 +1,20 @@
 package entity
 
 type User struct {
-	Id         string `json:"id" db:"id"`
-	Name       string `json:"name" db:"name"`
-	Password   string `json:"password" db:"password"`
-	CreateTime int64  `json:"createTime" db:"create_time"`
+	Id           string `json:"id" db:"id"`
+	Name         string `json:"name" db:"name"`
+	Password     string `json:"password,omitempty" db:"password"`
+	CreateTime   int64  `json:"createTime" db:"create_time"`
+	Role         string `json:"role" db:"role"`                // 角色：admin / user
+	Disabled     bool   `json:"disabled" db:"disabled"`        // 是否禁用，禁用后无法登录
+	TotpSecret   string `json:"-" db:"totp_secret"`            // 两步验证密钥，开启前为待确认的密钥
+	TotpEnabled  bool   `json:"totpEnabled" db:"totp_enabled"` // 是否已开启两步验证
+	TotpLastStep int64  `json:"-" db:"totp_last_step"`         // 最后使用的验证码时间步，防止重放
 }
 
 type UserCondition struct {
 	Id          string `json:"id"`
 	Password    string `json:"password"`
 	NewPassword string `json:"newPassword"`
+	Role        string `json:"role"`
 }
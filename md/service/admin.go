@@ -0,0 +1,286 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"os"
+	"path/filepath"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 查询全部用户
+func AdminUserList() []entity.User {
+	users, err := dao.UserList(middleware.Db)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return users
+}
+
+// 添加用户，不受是否允许注册的限制
+func AdminUserAdd(user entity.User) entity.User {
+	if user.Role == "" {
+		user.Role = common.RoleUser
+	}
+	checkRole(user.Role)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	user = userAdd(tx, user)
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+
+	middleware.Log.Infof("成功添加用户: {%s}", user.Name)
+	user.Password = ""
+	return user
+}
+
+// 修改用户角色
+func AdminUserUpdateRole(condition entity.UserCondition, currentUserId string) {
+	checkRole(condition.Role)
+	if condition.Id == currentUserId && condition.Role != common.RoleAdmin {
+		panic(common.NewError("不能取消自己的管理员角色"))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	user := adminUserGet(tx, condition.Id)
+	err := dao.UserUpdateRole(tx, user.Id, condition.Role)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+	checkAdminRemain(tx)
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+
+	middleware.Log.Infof("成功修改用户角色: {%s %s}", user.Name, condition.Role)
+}
+
+// 禁用或启用用户，禁用后用户的token全部失效
+func AdminUserUpdateDisabled(id string, disabled bool, currentUserId string) {
+	if id == currentUserId && disabled {
+		panic(common.NewError("不能禁用自己"))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	user := adminUserGet(tx, id)
+	err := dao.UserUpdateDisabled(tx, user.Id, disabled)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+	checkAdminRemain(tx)
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+
+	if disabled {
+		err = middleware.Tokens.DeleteByUser(user.Id)
+		if err != nil {
+			middleware.Log.Error("删除用户token失败：", err)
+		}
+	}
+
+	middleware.Log.Infof("成功修改用户禁用状态: {%s %t}", user.Name, disabled)
+}
+
+// 强制重置用户密码，重置后用户的token和个人访问令牌全部失效，需使用新密码重新登录
+func AdminUserResetPassword(condition entity.UserCondition) {
+	if condition.NewPassword == "" {
+		panic(common.NewError("密码不可为空"))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	user := adminUserGet(tx, condition.Id)
+	user.Password = passwordHash(condition.NewPassword)
+	err := dao.UserResetPassword(tx, user)
+	if err != nil {
+		panic(common.NewErr("重置失败", err))
+	}
+	err = dao.ApiTokenDeleteByUser(tx, user.Id)
+	if err != nil {
+		panic(common.NewErr("重置失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("重置失败", err))
+	}
+
+	err = middleware.Tokens.DeleteByUser(user.Id)
+	if err != nil {
+		middleware.Log.Error("删除用户token失败：", err)
+	}
+
+	middleware.Log.Infof("成功重置用户密码: {%s}", user.Name)
+}
+
+// 重置用户的两步验证，用于丢失验证器和恢复码的用户，重置后使用密码登录并可重新设置
+func AdminUserResetTotp(id string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	user := adminUserGet(tx, id)
+	totpClear(tx, user.Id)
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("重置失败", err))
+	}
+
+	middleware.Log.Infof("成功重置用户两步验证: {%s}", user.Name)
+}
+
+// 删除用户及其全部目录、文档、图片和标签，包括回收站中的
+func AdminUserDelete(id, currentUserId string) {
+	if id == currentUserId {
+		panic(common.NewError("不能删除自己"))
+	}
+
+	// 删除数据前按目录结构计算md文件路径
+	books, err := dao.BookList(middleware.Db, id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	documents, err := dao.DocumentListByUser(middleware.Db, id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	bookDirs := map[string]string{"": bookDirPath(entity.Book{})}
+	for _, book := range books {
+		bookDirs[book.Id] = bookDirPath(book)
+	}
+	files := []string{}
+	for _, document := range documents {
+		if dirPath, ok := bookDirs[document.BookId]; ok {
+			files = append(files, filepath.Join(dirPath, document.Name+entity.MdExt))
+		}
+	}
+
+	// 回收站中的文档、目录
+	deletedDocuments, err := dao.DocumentListDeleted(middleware.Db, 0, id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	for _, document := range deletedDocuments {
+		files = append(files, trashPath(string(entity.TrashDocument), document.Id+entity.MdExt))
+	}
+	deletedBooks, err := dao.BookListDeleted(middleware.Db, 0, id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	for _, book := range deletedBooks {
+		files = append(files, trashPath(string(entity.TrashBook), book.Id))
+	}
+
+	pictures, err := dao.PictureListByUser(middleware.Db, id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	user := adminUserGet(tx, id)
+	err = dao.UserDeleteById(tx, user.Id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	checkAdminRemain(tx)
+
+	// 查询图片文件是否仍被其他用户引用
+	pictureRefs := map[string][2]int{}
+	for _, picture := range pictures {
+		liveResult, err := dao.PictureCountByPath(tx, picture.Path)
+		if err != nil {
+			panic(common.NewErr("删除失败", err))
+		}
+		deletedResult, err := dao.PictureCountDeletedByPath(tx, picture.Path)
+		if err != nil {
+			panic(common.NewErr("删除失败", err))
+		}
+		pictureRefs[picture.Path] = [2]int{liveResult.Count, deletedResult.Count}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = middleware.Tokens.DeleteByUser(user.Id)
+	if err != nil {
+		middleware.Log.Error("删除用户token失败：", err)
+	}
+
+	// 删除md文件，再由内向外删除已为空的目录
+	for _, file := range files {
+		util.RemoveDir(file)
+	}
+	for i := len(books) - 1; i >= 0; i-- {
+		os.Remove(bookDirs[books[i].Id])
+	}
+
+	for path, refs := range pictureRefs {
+		for _, dirName := range []string{common.PictureName, common.ThumbnailName} {
+			switch {
+			case refs[0] > 0:
+				// 仍被其他用户使用
+			case refs[1] > 0:
+				// 仅被其他用户回收站中的记录引用，将文件移入回收站目录
+				if !util.IsDirExist(trashPath(dirName, path)) {
+					util.MoveFile(picturePath(dirName, path), trashPath(dirName, path))
+				}
+			default:
+				util.RemoveDir(picturePath(dirName, path))
+				util.RemoveDir(trashPath(dirName, path))
+			}
+		}
+	}
+	util.RefreshDir()
+
+	middleware.Log.Infof("成功删除用户: {%s}", user.Name)
+}
+
+// 查询用户，不存在时抛出异常
+func adminUserGet(tx *sqlx.Tx, id string) entity.User {
+	user, err := dao.UserGetById(tx, id)
+	if err != nil {
+		panic(common.NewErr("用户不存在", err))
+	}
+	return user
+}
+
+// 校验角色
+func checkRole(role string) {
+	if role != common.RoleAdmin && role != common.RoleUser {
+		panic(common.NewError("不支持的角色"))
+	}
+}
+
+// 校验至少保留一个未禁用的管理员
+func checkAdminRemain(tx *sqlx.Tx) {
+	countResult, err := dao.UserCountAdmin(tx)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	if countResult.Count == 0 {
+		panic(common.NewError("至少需保留一个可用的管理员"))
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 全部可选的令牌权限
+var apiTokenScopes = []string{common.ScopeDocRead, common.ScopeDocWrite, common.ScopePictureUpload, common.ScopePublish}
+
+// 查询个人访问令牌列表
+func ApiTokenList(userId string) []entity.ApiToken {
+	apiTokens, err := dao.ApiTokenList(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	for i := range apiTokens {
+		apiTokens[i].ScopeList = strings.Split(apiTokens[i].Scopes, ",")
+	}
+	return apiTokens
+}
+
+// 创建个人访问令牌，返回的令牌明文只显示这一次
+func ApiTokenAdd(condition entity.ApiTokenCondition, userId string) entity.ApiToken {
+	condition.Name = strings.TrimSpace(condition.Name)
+	if condition.Name == "" {
+		panic(common.NewError("令牌名称不可为空"))
+	}
+	if util.StringLength(condition.Name) > 50 {
+		panic(common.NewError("令牌名称不可大于50个字符"))
+	}
+	if len(condition.Scopes) == 0 {
+		panic(common.NewError("请选择令牌权限"))
+	}
+	scopes := []string{}
+	for _, scope := range condition.Scopes {
+		if !slices.Contains(apiTokenScopes, scope) {
+			panic(common.NewError("不支持的令牌权限：" + scope))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	now := time.Now().UnixMilli()
+	if condition.ExpireTime != 0 && condition.ExpireTime <= now {
+		panic(common.NewError("过期时间需晚于当前时间"))
+	}
+
+	token := common.ApiTokenPrefix + randomToken(32)
+	apiToken := entity.ApiToken{
+		Id:          util.SnowflakeString(),
+		Name:        condition.Name,
+		TokenHash:   middleware.TokenHash(token),
+		TokenPrefix: token[:len(common.ApiTokenPrefix)+6],
+		Scopes:      strings.Join(scopes, ","),
+		ScopeList:   scopes,
+		ExpireTime:  condition.ExpireTime,
+		CreateTime:  now,
+		UserId:      userId,
+		Token:       token,
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.ApiTokenAdd(tx, apiToken)
+	if err != nil {
+		panic(common.NewErr("创建失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("创建失败", err))
+	}
+
+	middleware.Log.Infof("成功创建令牌: {%s %s}", apiToken.Name, apiToken.Scopes)
+	return apiToken
+}
+
+// 撤销个人访问令牌
+func ApiTokenRevoke(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	apiToken, err := dao.ApiTokenGetById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("令牌不存在", err))
+	}
+
+	err = dao.ApiTokenDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("撤销失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("撤销失败", err))
+	}
+
+	middleware.Log.Infof("成功撤销令牌: {%s}", apiToken.Name)
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 备份文件格式版本
+const backupFormat = 1
+
+// 备份文件中的文件名
+const (
+	backupManifestName = "manifest.json"
+	backupSqliteName   = "md.db"
+	backupTablesDir    = "tables"
+)
+
+// 逻辑备份的表，按恢复时的插入顺序排列
+var backupTables = []string{"t_user", "t_book", "t_document", "t_document_revision", "t_document_share", "t_picture", "t_tag", "t_document_tag", "t_sync", "t_sync_path", "t_token", "t_recovery_code", "t_api_token"}
+
+// 校验当前用户是否可以备份
+func BackupCheck(userId string) {
+	user, err := dao.UserGetById(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	if user.Role != common.RoleAdmin {
+		panic(common.NewErrorCode(common.HttpForbidden, "仅管理员可以备份"))
+	}
+}
+
+// 备份数据库和图片为zip
+// sqlite使用在线备份生成数据库文件快照，postgres在可重复读事务中导出各表数据为json
+func Backup(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	manifest := entity.BackupManifest{
+		Format:        backupFormat,
+		Driver:        middleware.Db.DriverName(),
+		SchemaVersion: middleware.SchemaVersion(),
+		CreateTime:    time.Now().UnixMilli(),
+		Tables:        map[string]int{},
+	}
+
+	var err error
+	if manifest.Driver == "sqlite" {
+		err = backupSqlite(zw)
+	} else {
+		err = backupTablesJson(zw, manifest.Tables)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, dirName := range []string{common.PictureName, common.ThumbnailName} {
+		if err = backupDir(zw, dirName); err != nil {
+			return err
+		}
+	}
+
+	writer, err := zw.Create(backupManifestName)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(writer).Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// 备份到文件
+func BackupFile(out string) error {
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = Backup(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// 从备份文件恢复数据库和图片，会覆盖当前数据库中的全部数据
+func Restore(in string) error {
+	reader, err := zip.OpenReader(in)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// 校验备份文件
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		if !filepath.IsLocal(file.Name) {
+			return errors.New("备份文件中存在非法路径：" + file.Name)
+		}
+		files[file.Name] = file
+	}
+	manifest := entity.BackupManifest{}
+	if err = readZipJson(files[backupManifestName], &manifest); err != nil {
+		return errors.New("不是有效的备份文件：" + err.Error())
+	}
+	if manifest.Format != backupFormat {
+		return fmt.Errorf("不支持的备份文件格式：%d", manifest.Format)
+	}
+	if manifest.SchemaVersion > middleware.SchemaVersion() {
+		return errors.New("备份的数据库版本高于当前程序，请升级程序后恢复")
+	}
+
+	if manifest.Driver == "sqlite" {
+		err = restoreSqlite(files[backupSqliteName])
+	} else {
+		err = restoreTablesJson(files, manifest.Tables)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 数据目录中的md文件与恢复的数据不一致，清空同步记录，避免下次同步时误删
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+	for _, table := range []string{"t_sync", "t_sync_path"} {
+		if err = dao.TableClear(tx, table); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	// 恢复图片和缩略图
+	for name, file := range files {
+		dirName := strings.Split(name, "/")[0]
+		if file.FileInfo().IsDir() || (dirName != common.PictureName && dirName != common.ThumbnailName) {
+			continue
+		}
+		if err = extractZipFile(file, filepath.Join(common.DataPath, common.ResourceName, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+
+	middleware.Log.Infof("成功恢复备份: {%s} %s", manifest.Driver, time.UnixMilli(manifest.CreateTime).Format(time.DateTime))
+	return nil
+}
+
+// 在线备份sqlite数据库文件
+func backupSqlite(zw *zip.Writer) error {
+	dir, err := os.MkdirTemp("", "md-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, backupSqliteName)
+	if err = dao.SqliteBackup(middleware.Db, snapshot); err != nil {
+		return err
+	}
+	return addZipFile(zw, snapshot, backupSqliteName)
+}
+
+// 在同一个只读事务中导出各表数据，保证数据一致
+func backupTablesJson(zw *zip.Writer, tables map[string]int) error {
+	tx, err := middleware.Db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range backupTables {
+		rows, err := dao.TableRows(tx, table)
+		if err != nil {
+			return err
+		}
+		writer, err := zw.Create(path.Join(backupTablesDir, table+".json"))
+		if err != nil {
+			return err
+		}
+		if err = json.NewEncoder(writer).Encode(rows); err != nil {
+			return err
+		}
+		tables[table] = len(rows)
+	}
+	return nil
+}
+
+// 添加数据目录下的子目录
+func backupDir(zw *zip.Writer, dirName string) error {
+	root := filepath.Join(common.DataPath, common.ResourceName, dirName)
+	return filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		return addZipFile(zw, filePath, path.Join(dirName, filepath.ToSlash(rel)))
+	})
+}
+
+// 校验并替换sqlite数据库文件，替换后重新连接数据库并执行迁移
+func restoreSqlite(file *zip.File) error {
+	if middleware.Db.DriverName() != "sqlite" {
+		return errors.New("sqlite备份只能恢复到sqlite数据库")
+	}
+	if file == nil {
+		return errors.New("备份文件中缺少数据库文件")
+	}
+
+	restorePath := middleware.SqlitePath() + ".restore"
+	defer os.Remove(restorePath)
+	if err := extractZipFile(file, restorePath); err != nil {
+		return err
+	}
+
+	// 校验数据库文件完整且包含版本记录
+	db, err := sqlx.Connect("sqlite", restorePath)
+	if err != nil {
+		return err
+	}
+	err = dao.SqliteIntegrityCheck(db)
+	if err == nil {
+		var count int
+		if db.Get(&count, `select count(*) from t_schema_version`) != nil {
+			err = errors.New("备份文件中的数据库缺少版本记录")
+		}
+	}
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	// 删除当前数据库的日志文件，避免被应用到恢复的数据库文件上
+	middleware.CloseDB()
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(middleware.SqlitePath() + suffix)
+	}
+	if err = os.Rename(restorePath, middleware.SqlitePath()); err != nil {
+		return err
+	}
+	return middleware.InitDB()
+}
+
+// 读取并校验全部表数据后，在一个事务中清空并写入各表
+func restoreTablesJson(files map[string]*zip.File, tables map[string]int) error {
+	data := map[string][]map[string]interface{}{}
+	for _, table := range backupTables {
+		count, ok := tables[table]
+		if !ok {
+			continue
+		}
+		rows := []map[string]interface{}{}
+		if err := readZipJson(files[path.Join(backupTablesDir, table+".json")], &rows); err != nil {
+			return fmt.Errorf("读取表数据失败：%s %s", table, err)
+		}
+		if len(rows) != count {
+			return fmt.Errorf("表数据行数不一致：%s", table)
+		}
+		data[table] = rows
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	for _, table := range backupTables {
+		if err := dao.TableClear(tx, table); err != nil {
+			return err
+		}
+		for _, row := range data[table] {
+			if err := dao.TableInsert(tx, table, row); err != nil {
+				return fmt.Errorf("写入表数据失败：%s %s", table, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// 读取zip中的json文件，数字保留为整数
+func readZipJson(file *zip.File, v interface{}) error {
+	if file == nil {
+		return errors.New("文件不存在")
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	if err = decoder.Decode(v); err != nil {
+		return err
+	}
+	convertJsonNumbers(v)
+	return nil
+}
+
+// 将json.Number转换为int64或float64，便于作为数据库参数
+func convertJsonNumbers(v interface{}) {
+	rows, ok := v.(*[]map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, row := range *rows {
+		for k, value := range row {
+			number, ok := value.(json.Number)
+			if !ok {
+				continue
+			}
+			if i, err := number.Int64(); err == nil {
+				row[k] = i
+			} else if f, err := number.Float64(); err == nil {
+				row[k] = f
+			}
+		}
+	}
+}
+
+// 添加文件到zip
+func addZipFile(zw *zip.Writer, filePath, name string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// 解压zip中的文件到指定路径
+func extractZipFile(file *zip.File, target string) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, reader); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"md/dao"
+	"md/middleware"
+	"md/model/entity"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 生成测试用的备份文件，manifest为nil时不写入备份清单
+func backupTestZip(t *testing.T, manifest *entity.BackupManifest, names ...string) string {
+	t.Helper()
+	zipPath := filepath.Join(t.TempDir(), "backup.zip")
+	file, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+	for _, name := range names {
+		if _, err = zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if manifest != nil {
+		writer, err := zw.Create(backupManifestName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.NewEncoder(writer).Encode(manifest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
+
+func TestRestoreValidate(t *testing.T) {
+	notZip := filepath.Join(t.TempDir(), "backup.zip")
+	if err := os.WriteFile(notZip, []byte("not a zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := func(format, schemaVersion int) *entity.BackupManifest {
+		return &entity.BackupManifest{Format: format, Driver: "sqlite", SchemaVersion: schemaVersion}
+	}
+
+	tests := []struct {
+		name    string
+		in      string
+		message string
+	}{
+		{"不是zip文件", notZip, "zip: not a valid zip file"},
+		{"缺少备份清单", backupTestZip(t, nil, backupSqliteName), "不是有效的备份文件"},
+		{"格式不支持", backupTestZip(t, manifest(backupFormat+1, 1), backupSqliteName), "不支持的备份文件格式"},
+		{"数据库版本较高", backupTestZip(t, manifest(backupFormat, middleware.SchemaVersion()+1), backupSqliteName), "备份的数据库版本高于当前程序"},
+		{"非法路径", backupTestZip(t, manifest(backupFormat, 1), "../md.db"), "备份文件中存在非法路径"},
+		{"绝对路径", backupTestZip(t, manifest(backupFormat, 1), "/picture/a.png"), "备份文件中存在非法路径"},
+		{"缺少数据库文件", backupTestZip(t, manifest(backupFormat, 1)), "备份文件中缺少数据库文件"},
+		{"数据库文件无效", backupTestZip(t, manifest(backupFormat, 1), backupSqliteName), "备份文件中的数据库缺少版本记录"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Restore(tt.in)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.message) {
+				t.Errorf("错误信息为 %v，期望 %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	userId := testUser(t)
+	backupPath := filepath.Join(t.TempDir(), "backup.zip")
+	if err := BackupFile(backupPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复后备份之后的数据不存在
+	laterId := testUser(t)
+	if err := Restore(backupPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.UserGetById(middleware.Db, userId); err != nil {
+		t.Errorf("备份前的用户不存在: %v", err)
+	}
+	if _, err := dao.UserGetById(middleware.Db, laterId); err == nil {
+		t.Error("备份后添加的用户仍存在")
+	}
+	if middleware.SchemaVersion() == 0 {
+		t.Error("恢复后缺少版本记录")
+	}
+
+	// 恢复后重新连接的数据库可以写入
+	testBook(t, testUser(t), "", "恢复")
+}
@@ -7,60 +7,15 @@ import (
 	"md/model/entity"
 	"md/util"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
-)
-
-func RefreshBook(book entity.Book, parentName string) {
-	tx := middleware.DbW.MustBegin()
-	defer tx.Rollback()
-
-	user, err := dao.UserGetByName(middleware.Db, "admin")
-	if err != nil {
-		return
-	}
-
-	book.Id = util.SnowflakeString()
-	book.CreateTime = time.Now().UnixMilli()
-	book.UserId = user.Id
 
-	// 根据名称查询一目录列表
-	book.Name = strings.TrimSpace(book.Name)
-	books, err := dao.BookListByName(tx, book.Name, book.UserId)
-	if err != nil {
-		return
-	}
-	if len(books) > 0 {
-		return
-	}
-
-	if parentName != "" {
-		// 根据名称查询一目录列表
-		parentName = strings.TrimSpace(parentName)
-		books, err := dao.BookListByName(tx, parentName, book.UserId)
-		if err != nil {
-			return
-		}
-		if len(books) == 0 {
-			return
-		}
-		book.ParentId = books[0].Id
-	}
-
-	// 保存
-	err = dao.BookAdd(tx, book)
-	if err != nil {
-		return
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return
-	}
-}
+	"github.com/jmoiron/sqlx"
+)
 
 // 添加目录
-func BookAdd(book entity.Book) {
+func BookAdd(book entity.Book) entity.Book {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
 
@@ -81,9 +36,20 @@ func BookAdd(book entity.Book) {
 		panic(common.NewError("已存在同名目录"))
 	}
 
-	// 保存
+	// 上级目录必须属于当前用户
+	var parent entity.Book
+	if book.ParentId != "" {
+		parent = bookOfUser(book.ParentId, book.UserId)
+	}
+
+	// 保存，排在同级目录的最后
+	maxIndex, err := dao.BookMaxSortIndex(tx, book.ParentId, book.UserId)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
 	book.Id = util.SnowflakeString()
 	book.CreateTime = time.Now().UnixMilli()
+	book.SortIndex = nextSortIndex(maxIndex)
 	err = dao.BookAdd(tx, book)
 	if err != nil {
 		panic(common.NewErr("添加失败", err))
@@ -94,15 +60,13 @@ func BookAdd(book entity.Book) {
 		panic(common.NewErr("添加失败", err))
 	}
 
+	dirPath := filepath.Join(bookDirPath(parent), book.Name)
 	go func() {
-		var rootBook entity.Book
-		if book.ParentId != "" {
-			rootBook = Book(book.ParentId)
-		}
-		util.CreateDir(common.DataPath, common.ResourceName, rootBook.Name, book.Name)
+		util.CreateDir(dirPath)
 	}()
 
 	middleware.Log.Infof("成功添加一级目录: {%s}", book.Name)
+	return book
 }
 
 // 修改目录
@@ -110,7 +74,7 @@ func BookUpdate(book entity.Book) {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
 
-	oldBook := Book(book.Id)
+	oldBook := bookOfUser(book.Id, book.UserId)
 
 	book.Name = strings.TrimSpace(book.Name)
 	if book.Name == "" {
@@ -134,30 +98,29 @@ func BookUpdate(book entity.Book) {
 	}
 
 	// 更新名称
-	err = dao.BookUpdate(tx, book)
+	ok, err := dao.BookUpdate(tx, book)
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
+	if !ok {
+		panic(common.NewError("目录不存在"))
+	}
 
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
 
+	oldPath := bookDirPath(oldBook)
+	newPath := filepath.Join(filepath.Dir(oldPath), book.Name)
 	go func() {
-		var rootBook entity.Book
-		if oldBook.ParentId != "" {
-			rootBook = Book(oldBook.ParentId)
-		}
-		oldPath := filepath.Join(common.DataPath, common.ResourceName, rootBook.Name, oldBook.Name)
-		newPath := filepath.Join(common.DataPath, common.ResourceName, rootBook.Name, book.Name)
 		util.RenameDir(oldPath, newPath)
 	}()
 
 	middleware.Log.Infof("成功更新目录名称: {%s}", book.Name)
 }
 
-// 删除目录
+// 删除目录，移入回收站
 func BookDelete(id, userId string) {
 	documents, err := dao.DocumentList(middleware.Db, id, userId)
 	if err != nil {
@@ -178,13 +141,17 @@ func BookDelete(id, userId string) {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
 
-	book := Book(id)
+	book := bookOfUser(id, userId)
+	dirPath := bookDirPath(book)
 
-	// 删除
-	err = dao.BookDeleteById(tx, id, userId)
+	// 移入回收站
+	ok, err := dao.BookSoftDelete(tx, id, time.Now().UnixMilli(), userId)
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
+	if !ok {
+		panic(common.NewError("目录不存在"))
+	}
 
 	err = tx.Commit()
 	if err != nil {
@@ -192,11 +159,7 @@ func BookDelete(id, userId string) {
 	}
 
 	go func() {
-		var rootBook entity.Book
-		if book.ParentId != "" {
-			rootBook = Book(book.ParentId)
-		}
-		util.RemoveDir(common.DataPath, common.ResourceName, rootBook.Name, book.Name)
+		util.MoveFile(dirPath, trashPath(string(entity.TrashBook), id))
 	}()
 
 	middleware.Log.Infof("成功删除一级目录: {%s}", book.Name)
@@ -214,6 +177,116 @@ func BookList(userId string) []entity.Book {
 	return books
 }
 
+// 查询目录树
+func BookTree(userId string) []entity.BookTree {
+	books, err := dao.BookList(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	children := map[string][]entity.Book{}
+	for _, book := range books {
+		children[book.ParentId] = append(children[book.ParentId], book)
+	}
+
+	var build func(parentId string) []entity.BookTree
+	build = func(parentId string) []entity.BookTree {
+		nodes := []entity.BookTree{}
+		for _, book := range children[parentId] {
+			nodes = append(nodes, entity.BookTree{Book: book, Children: build(book.Id)})
+		}
+		return nodes
+	}
+	return build("")
+}
+
+// 移动目录到新的上级目录下，parentId为空时移动为一级目录
+func BookMove(book entity.Book) {
+	oldBook := bookOfUser(book.Id, book.UserId)
+	if oldBook.ParentId == book.ParentId {
+		return
+	}
+
+	// 新的上级目录不能是自身或子目录
+	var parent entity.Book
+	if book.ParentId != "" {
+		parent = bookOfUser(book.ParentId, book.UserId)
+		for _, ancestor := range append(bookAncestors(parent), parent) {
+			if ancestor.Id == book.Id {
+				panic(common.NewError("不能移动到自身或子目录下"))
+			}
+		}
+	}
+
+	oldPath := bookDirPath(oldBook)
+	parentPath := bookDirPath(parent)
+	newPath := filepath.Join(parentPath, oldBook.Name)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	// 排在新的同级目录的最后
+	maxIndex, err := dao.BookMaxSortIndex(tx, book.ParentId, book.UserId)
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
+	err = dao.BookUpdateParent(tx, book.Id, book.ParentId, nextSortIndex(maxIndex), book.UserId)
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
+
+	// 层级变化后，文档中图片的相对路径随之调整
+	files, err := bookRelinkPictures(tx, oldBook, newPath, bookDepth(parent)+1)
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
+
+	go func() {
+		util.CreateDir(parentPath)
+		util.RenameDir(oldPath, newPath)
+		for filePath, content := range files {
+			util.CreateFile(filepath.Dir(filePath), filepath.Base(filePath), []byte(content))
+		}
+		util.RefreshDir()
+	}()
+
+	middleware.Log.Infof("成功移动目录: {%s}", oldBook.Name)
+}
+
+// 按id列表的顺序调整目录顺序
+func BookReorder(ids []string, userId string) {
+	// 只能调整同一上级目录下子目录的顺序
+	parentId := ""
+	for i, id := range ids {
+		book := bookOfUser(id, userId)
+		if i == 0 {
+			parentId = book.ParentId
+		} else if book.ParentId != parentId {
+			panic(common.NewError("只能调整同一上级目录下子目录的顺序"))
+		}
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	for i, id := range ids {
+		err := dao.BookUpdateSortIndex(tx, id, int64(i+1), userId)
+		if err != nil {
+			panic(common.NewErr("排序失败", err))
+		}
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("排序失败", err))
+	}
+}
+
 // 查询一级目录
 func Book(id string) entity.Book {
 	book, err := dao.Book(middleware.Db, id)
@@ -223,3 +296,105 @@ func Book(id string) entity.Book {
 
 	return book
 }
+
+// 查询属于指定用户的目录
+func bookOfUser(id, userId string) entity.Book {
+	book, err := dao.Book(middleware.Db, id)
+	if err != nil || book.UserId != userId {
+		panic(common.NewErr("目录不存在", err))
+	}
+	return book
+}
+
+// 查询全部上级目录，按从一级目录到直接上级目录排列
+func bookAncestors(book entity.Book) []entity.Book {
+	ancestors := []entity.Book{}
+	// 限制层级，避免数据异常时出现死循环
+	for book.ParentId != "" && len(ancestors) < 100 {
+		book = Book(book.ParentId)
+		ancestors = append([]entity.Book{book}, ancestors...)
+	}
+	return ancestors
+}
+
+// 目录在数据目录中的完整路径，未指定目录时返回数据根目录
+func bookDirPath(book entity.Book) string {
+	names := []string{common.DataPath, common.ResourceName}
+	if book.Id == "" {
+		return filepath.Join(names...)
+	}
+	for _, ancestor := range bookAncestors(book) {
+		names = append(names, ancestor.Name)
+	}
+	return filepath.Join(append(names, book.Name)...)
+}
+
+// 目录层级，一级目录为1，未指定目录时为0
+func bookDepth(book entity.Book) int {
+	if book.Id == "" {
+		return 0
+	}
+	return len(bookAncestors(book)) + 1
+}
+
+// 将目录及子目录下文档中的图片相对路径调整为新的层级，返回需要重写的文件路径和内容
+// 参数 dirPath 表示目录移动后的路径
+// 参数 depth 表示目录移动后的层级
+func bookRelinkPictures(tx *sqlx.Tx, book entity.Book, dirPath string, depth int) (map[string]string, error) {
+	files := map[string]string{}
+	documents, err := dao.DocumentList(middleware.Db, book.Id, book.UserId)
+	if err != nil {
+		return files, err
+	}
+	for _, document := range documents {
+		document, err = dao.DocumentGetById(middleware.Db, document.Id, book.UserId)
+		if err != nil {
+			return files, err
+		}
+		content := pictureRelink(document.Content, depth)
+		if content == document.Content {
+			continue
+		}
+		// 与其他内容修改一致，先将原内容保存为历史版本
+		if err = documentRevisionSave(tx, document, book.UserId); err != nil {
+			return files, err
+		}
+		document.Content = content
+		document.UserId = book.UserId
+		document.UpdateTime = time.Now().UnixMilli()
+		if err = dao.DocumentUpdateContent(tx, document); err != nil {
+			return files, err
+		}
+		files[filepath.Join(dirPath, document.Name+entity.MdExt)] = content
+	}
+
+	children, err := dao.BookByParentId(middleware.Db, book.UserId, book.Id)
+	if err != nil {
+		return files, err
+	}
+	for _, child := range children {
+		childFiles, err := bookRelinkPictures(tx, child, filepath.Join(dirPath, child.Name), depth+1)
+		if err != nil {
+			return files, err
+		}
+		for filePath, content := range childFiles {
+			files[filePath] = content
+		}
+	}
+	return files, nil
+}
+
+// 将文档中图片的相对路径调整为指定层级，如层级2时为 ../../picture/
+func pictureRelink(content string, depth int) string {
+	re := regexp.MustCompile(`\]\(\s*(\.\./)+` + regexp.QuoteMeta(common.PictureName) + `/`)
+	return re.ReplaceAllString(content, "]("+strings.Repeat("../", depth)+common.PictureName+"/")
+}
+
+// 新添加或移入的目录、文档的排序序号
+// 同级未调整过顺序时（序号都为0）保持按名称排序，否则排在最后
+func nextSortIndex(maxIndex int64) int64 {
+	if maxIndex == 0 {
+		return 0
+	}
+	return maxIndex + 1
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"md/dao"
+	"md/middleware"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 复制数据库时各表的分页排序列，保证分页结果稳定
+var copyTableOrders = map[string]string{
+	"t_user":              "id",
+	"t_book":              "id",
+	"t_document":          "id",
+	"t_document_revision": "id",
+	"t_document_share":    "id",
+	"t_picture":           "id",
+	"t_tag":               "id",
+	"t_document_tag":      "document_id,tag_id",
+	"t_sync":              "user_id",
+	"t_sync_path":         "user_id,path",
+	"t_token":             "token",
+	"t_recovery_code":     "user_id,code",
+	"t_api_token":         "id",
+}
+
+// 在sqlite与postgres之间复制全部数据
+// 两端先执行数据库迁移使表结构一致，在源库的只读快照中分批读取，在目标库的一个事务中分批写入，
+// 写入后校验各表行数，不一致时回滚并返回错误。目标库已有数据时需指定overwrite才会清空后写入
+func CopyDb(from, to string, overwrite bool, batchSize int) error {
+	if from != "sqlite" && from != "postgres" || to != "sqlite" && to != "postgres" {
+		return errors.New("数据库类型只能是sqlite或postgres")
+	}
+	if from == to {
+		return errors.New("源数据库与目标数据库不能相同")
+	}
+	if batchSize <= 0 {
+		return errors.New("批量大小必须大于0")
+	}
+
+	source, err := openDb(from)
+	if err != nil {
+		return fmt.Errorf("连接源数据库失败：%s", err)
+	}
+	defer source.Close()
+
+	target, err := openDb(to)
+	if err != nil {
+		return fmt.Errorf("连接目标数据库失败：%s", err)
+	}
+	defer target.Close()
+
+	if err = middleware.MigrateDb(source); err != nil {
+		return fmt.Errorf("源数据库迁移失败：%s", err)
+	}
+	if err = middleware.MigrateDb(target); err != nil {
+		return fmt.Errorf("目标数据库迁移失败：%s", err)
+	}
+
+	// 源库只读快照，复制期间的写入不影响结果
+	sourceTx, err := beginSnapshot(source)
+	if err != nil {
+		return err
+	}
+	defer sourceTx.Rollback()
+
+	targetTx, err := target.Beginx()
+	if err != nil {
+		return err
+	}
+	defer targetTx.Rollback()
+
+	if !overwrite {
+		for _, table := range backupTables {
+			count, err := dao.TableCount(targetTx, table)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("目标数据库已有数据：%s，如需覆盖请使用-overwrite", table)
+			}
+		}
+	}
+
+	// 按插入顺序的逆序清空
+	for i := len(backupTables) - 1; i >= 0; i-- {
+		if err = dao.TableClear(targetTx, backupTables[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range backupTables {
+		count, err := copyTable(sourceTx, targetTx, table, batchSize)
+		if err != nil {
+			return fmt.Errorf("复制表数据失败：%s %s", table, err)
+		}
+		middleware.Log.Infof("复制表: {%s} %d行", table, count)
+	}
+
+	// 校验行数
+	mismatch := 0
+	for _, table := range backupTables {
+		sourceCount, err := dao.TableCount(sourceTx, table)
+		if err != nil {
+			return err
+		}
+		targetCount, err := dao.TableCount(targetTx, table)
+		if err != nil {
+			return err
+		}
+		if sourceCount != targetCount {
+			mismatch++
+			middleware.Log.Errorf("表行数不一致: {%s} 源%d行 目标%d行", table, sourceCount, targetCount)
+		}
+	}
+	if mismatch > 0 {
+		return fmt.Errorf("%d个表行数不一致，已回滚", mismatch)
+	}
+
+	return targetTx.Commit()
+}
+
+// 按数据库类型开启连接
+func openDb(driver string) (*sqlx.DB, error) {
+	if driver == "postgres" {
+		return middleware.OpenPostgres()
+	}
+	return middleware.OpenSqlite()
+}
+
+// 开启只读快照事务，sqlite的读事务本身即为快照
+func beginSnapshot(db *sqlx.DB) (*sqlx.Tx, error) {
+	if db.DriverName() == "postgres" {
+		return db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	}
+	return db.Beginx()
+}
+
+// 分批复制一个表，返回复制的行数
+func copyTable(sourceTx, targetTx *sqlx.Tx, table string, batchSize int) (int, error) {
+	count := 0
+	for {
+		rows, err := dao.TableRowsPage(sourceTx, table, copyTableOrders[table], batchSize, count)
+		if err != nil {
+			return count, err
+		}
+		for _, row := range rows {
+			if err = dao.TableInsert(targetTx, table, row); err != nil {
+				return count, err
+			}
+		}
+		count += len(rows)
+		if len(rows) < batchSize {
+			return count, nil
+		}
+	}
+}
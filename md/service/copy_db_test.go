@@ -0,0 +1,80 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestCopyDbArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  string
+		batchSize int
+		message   string
+	}{
+		{"不支持的源数据库", "mysql", "postgres", 1000, "数据库类型只能是sqlite或postgres"},
+		{"不支持的目标数据库", "sqlite", "", 1000, "数据库类型只能是sqlite或postgres"},
+		{"相同数据库", "sqlite", "sqlite", 1000, "源数据库与目标数据库不能相同"},
+		{"批量大小为0", "sqlite", "postgres", 0, "批量大小必须大于0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CopyDb(tt.from, tt.to, false, tt.batchSize)
+			if err == nil || err.Error() != tt.message {
+				t.Errorf("错误信息为 %v，期望 %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestCopyTable(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		testDocument(t, testUser(t), "", "复制", "")
+	}
+
+	target, err := sqlx.Connect("sqlite", filepath.Join(t.TempDir(), "copy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	if err = middleware.MigrateDb(target); err != nil {
+		t.Fatal(err)
+	}
+
+	sourceTx, err := beginSnapshot(middleware.Db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sourceTx.Rollback()
+	targetTx, err := target.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer targetTx.Rollback()
+
+	// 每个表都需要分页排序列，批量小于行数时分多批复制
+	for _, table := range backupTables {
+		if copyTableOrders[table] == "" {
+			t.Fatalf("缺少分页排序列: %s", table)
+		}
+		count, err := copyTable(sourceTx, targetTx, table, 2)
+		if err != nil {
+			t.Fatalf("复制表 %s 失败: %v", table, err)
+		}
+		sourceCount, err := dao.TableCount(sourceTx, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targetCount, err := dao.TableCount(targetTx, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != sourceCount || targetCount != sourceCount {
+			t.Errorf("表 %s 复制 %d 行，目标 %d 行，源 %d 行", table, count, targetCount, sourceCount)
+		}
+	}
+}
@@ -9,58 +9,12 @@ import (
 	"md/util"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-func RefreshDocument(document entity.Document, parentName string) {
-	tx := middleware.DbW.MustBegin()
-	defer tx.Rollback()
-
-	user, err := dao.UserGetByName(middleware.Db, "admin")
-	if err != nil {
-		return
-	}
-
-	document.Id = util.SnowflakeString()
-	document.Type = entity.DocMd
-	document.CreateTime = util.CreateStamp()
-	document.UpdateTime = util.CreateStamp()
-	document.UserId = user.Id
-	document.Name = strings.TrimSpace(document.Name)
-	// 根据名称查询文档是否存在
-	docs, err := dao.DocumentGetName(middleware.Db, document.Name, document.UserId)
-	if err != nil {
-		return
-	}
-	if len(docs) > 0 {
-		return
-	}
-
-	if parentName != "" {
-		// 根据名称查询一目录列表
-		parentName = strings.TrimSpace(parentName)
-		books, err := dao.BookListByName(tx, parentName, document.UserId)
-		if err != nil {
-			return
-		}
-		if len(books) == 0 {
-			return
-		}
-		document.BookId = books[0].Id
-	}
-
-	err = dao.DocumentAdd(tx, document)
-	if err != nil {
-		panic(common.NewErr("添加失败", err))
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		panic(common.NewErr("添加失败", err))
-	}
-}
-
 // 添加文档
 func DocumentAdd(document entity.Document) entity.Document {
 	tx := middleware.DbW.MustBegin()
@@ -91,9 +45,17 @@ func DocumentAdd(document entity.Document) entity.Document {
 		panic(common.NewError("已存在同名文档"))
 	}
 
+	// 排在目录下文档的最后
+	maxIndex, err := dao.DocumentMaxSortIndex(tx, document.BookId, document.UserId)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+
 	document.Id = util.SnowflakeString()
 	document.CreateTime = util.CreateStamp()
 	document.UpdateTime = util.CreateStamp()
+	document.Version = 1
+	document.SortIndex = nextSortIndex(maxIndex)
 	err = dao.DocumentAdd(tx, document)
 	if err != nil {
 		panic(common.NewErr("添加失败", err))
@@ -106,14 +68,10 @@ func DocumentAdd(document entity.Document) entity.Document {
 
 	go func() {
 		book := Book(document.BookId)
-		var rootBook entity.Book
-		if book.ParentId != "" {
-			rootBook = Book(book.ParentId)
-		}
 
 		// 生成文件
-		filePath := filepath.Join(common.DataPath, common.ResourceName, rootBook.Name, book.Name)
-		util.CreateFile(filePath, document.Name+entity.MdExt, []byte(""))
+		filePath := bookDirPath(book)
+		util.CreateFile(filePath, document.Name+entity.MdExt, []byte(document.Content))
 		util.RefreshDir()
 	}()
 
@@ -123,11 +81,6 @@ func DocumentAdd(document entity.Document) entity.Document {
 
 // 修改文档基础信息
 func DocumentUpdate(document entity.Document) {
-	tx := middleware.DbW.MustBegin()
-	defer tx.Rollback()
-
-	book := Book(document.BookId)
-
 	document.Name = strings.TrimSpace(document.Name)
 	if document.Name == "" {
 		panic(common.NewError("文档名称不可为空"))
@@ -141,6 +94,11 @@ func DocumentUpdate(document entity.Document) {
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
+	// 所属目录只能通过移动接口修改，md文件按原目录重命名
+	book := Book(doc.BookId)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
 
 	err = dao.DocumentUpdate(tx, document)
 	if err != nil {
@@ -153,13 +111,8 @@ func DocumentUpdate(document entity.Document) {
 	}
 
 	go func() {
-		var rootBook entity.Book
-		if book.ParentId != "" {
-			rootBook = Book(book.ParentId)
-		}
-
 		// 重命名
-		dirPath := filepath.Join(common.DataPath, common.ResourceName, rootBook.Name, book.Name)
+		dirPath := bookDirPath(book)
 		util.RenameFile(dirPath, doc.Name+entity.MdExt, document.Name+entity.MdExt)
 		util.RefreshDir()
 	}()
@@ -171,10 +124,8 @@ func DocumentUpdate(document entity.Document) {
 func DocumentUpdateContent(document entity.Document) entity.Document {
 	doc := DocumentGet(document.Id, document.UserId)
 	book := Book(doc.BookId)
-	var rootBook entity.Book
-	if book.ParentId != "" {
-		rootBook = Book(book.ParentId)
-	}
+	// 图片相对于文档所在目录的路径前缀
+	picturePrefix := strings.Repeat("../", bookDepth(book)) + common.PictureName + "/"
 
 	// 正则表达式模式，匹配图片URL
 	pattern := `\((https?://[^)]*/` + common.PictureName + `/[^"\s]+)\)`
@@ -195,13 +146,8 @@ func DocumentUpdateContent(document entity.Document) entity.Document {
 		matchStr := document.Content[start:end]
 		splitURL := strings.Split(matchStr, "/"+common.PictureName+"/")
 		if len(splitURL) > 1 {
-			if book.ParentId != "" {
-				modifiedURL := "(../../" + common.PictureName + "/" + strings.Join(splitURL[1:], "")
-				modifiedContent.WriteString(modifiedURL)
-			} else {
-				modifiedURL := "(../" + common.PictureName + "/" + strings.Join(splitURL[1:], "")
-				modifiedContent.WriteString(modifiedURL)
-			}
+			modifiedURL := "(" + picturePrefix + strings.Join(splitURL[1:], "")
+			modifiedContent.WriteString(modifiedURL)
 		} else {
 			// 如果没有找到/picture，原样保留
 			modifiedContent.WriteString(matchStr)
@@ -222,20 +168,42 @@ func DocumentUpdateContent(document entity.Document) entity.Document {
 		panic(common.NewError("文档内容过多，请小于1000万个字符"))
 	}
 
+	// 内容有变化时，将原内容保存为历史版本
+	if doc.Content != document.Content {
+		err := documentRevisionSave(tx, doc, document.UserId)
+		if err != nil {
+			panic(common.NewErr("更新失败", err))
+		}
+	}
+
 	document.UpdateTime = time.Now().UnixMilli()
-	err := dao.DocumentUpdateContent(tx, document)
-	if err != nil {
-		panic(common.NewErr("更新失败", err))
+	if document.ExpectedVersion == 0 {
+		err := dao.DocumentUpdateContent(tx, document)
+		if err != nil {
+			panic(common.NewErr("更新失败", err))
+		}
+		document.Version = doc.Version + 1
+	} else {
+		// 文档已被其他请求修改时拒绝保存，返回服务端当前内容用于合并
+		updated, err := dao.DocumentUpdateContentIfUnchanged(tx, document, document.ExpectedVersion)
+		if err != nil {
+			panic(common.NewErr("更新失败", err))
+		}
+		if !updated {
+			tx.Rollback()
+			panic(common.NewErrorData(common.HttpConflict, "文档已被修改，请合并后重新保存", DocumentGet(document.Id, document.UserId)))
+		}
+		document.Version = document.ExpectedVersion + 1
 	}
 
-	err = tx.Commit()
+	err := tx.Commit()
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
 
 	go func() {
 		// 将文档写入markdown文件
-		filePath := filepath.Join(common.DataPath, common.ResourceName, rootBook.Name, book.Name)
+		filePath := bookDirPath(book)
 		util.CreateFile(filePath, doc.Name+entity.MdExt, []byte(document.Content))
 		util.RefreshDir()
 	}()
@@ -244,14 +212,18 @@ func DocumentUpdateContent(document entity.Document) entity.Document {
 	return document
 }
 
-// 删除文档
+// 删除文档，移入回收站
 func DocumentDelete(id, userId string) {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
 
 	doc := DocumentGet(id, userId)
+	var book entity.Book
+	if doc.BookId != "" {
+		book = Book(doc.BookId)
+	}
 
-	err := dao.DocumentDeleteById(tx, id, userId)
+	err := dao.DocumentSoftDelete(tx, id, time.Now().UnixMilli(), userId)
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
@@ -261,29 +233,114 @@ func DocumentDelete(id, userId string) {
 		panic(common.NewErr("删除失败", err))
 	}
 
+	// 将md文件移入回收站目录
+	filePath := filepath.Join(bookDirPath(book), doc.Name+entity.MdExt)
 	go func() {
-		book := Book(doc.BookId)
-		var rootBook entity.Book
-		if book.ParentId != "" {
-			rootBook = Book(book.ParentId)
+		util.MoveFile(filePath, trashPath(string(entity.TrashDocument), id+entity.MdExt))
+		util.RefreshDir()
+	}()
+
+	middleware.Log.Infof("成功删除文档: {%s}", id)
+}
+
+// 移动文档到其他目录，同时移动磁盘上的md文件
+func DocumentMove(document entity.Document) {
+	doc := DocumentGet(document.Id, document.UserId)
+	if doc.BookId == document.BookId {
+		return
+	}
+	var oldBook entity.Book
+	if doc.BookId != "" {
+		oldBook = Book(doc.BookId)
+	}
+	book := bookOfUser(document.BookId, document.UserId)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	// 排在目标目录下文档的最后
+	maxIndex, err := dao.DocumentMaxSortIndex(tx, book.Id, document.UserId)
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
+	err = dao.DocumentUpdateBook(tx, doc.Id, book.Id, nextSortIndex(maxIndex), document.UserId)
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
+
+	// 目录层级变化时，调整图片的相对路径
+	content := pictureRelink(doc.Content, bookDepth(book))
+	if content != doc.Content {
+		err = documentRevisionSave(tx, doc, document.UserId)
+		if err != nil {
+			panic(common.NewErr("移动失败", err))
+		}
+		err = dao.DocumentUpdateContent(tx, entity.Document{Id: doc.Id, Content: content, UpdateTime: time.Now().UnixMilli(), UserId: document.UserId})
+		if err != nil {
+			panic(common.NewErr("移动失败", err))
 		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
 
-		// 删除文档
-		filePath := filepath.Join(common.DataPath, common.ResourceName, rootBook.Name, book.Name)
-		util.RemoveFile(filePath, doc.Name+entity.MdExt)
+	oldPath := bookDirPath(oldBook)
+	newPath := bookDirPath(book)
+	go func() {
+		util.CreateFile(newPath, doc.Name+entity.MdExt, []byte(content))
+		util.RemoveFile(oldPath, doc.Name+entity.MdExt)
 		util.RefreshDir()
 	}()
 
-	middleware.Log.Infof("成功删除文档: {%s}", id)
+	middleware.Log.Infof("成功移动文档: {%s}", doc.Name)
+}
+
+// 按id列表的顺序调整文档顺序
+func DocumentReorder(ids []string, userId string) {
+	// 只能调整同一目录下文档的顺序
+	bookId := ""
+	for i, id := range ids {
+		doc, err := dao.DocumentGetById(middleware.Db, id, userId)
+		if err != nil {
+			panic(common.NewErr("文档不存在", err))
+		}
+		if i == 0 {
+			bookId = doc.BookId
+		} else if doc.BookId != bookId {
+			panic(common.NewError("只能调整同一目录下文档的顺序"))
+		}
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	for i, id := range ids {
+		err := dao.DocumentUpdateSortIndex(tx, id, int64(i+1), userId)
+		if err != nil {
+			panic(common.NewErr("排序失败", err))
+		}
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("排序失败", err))
+	}
 }
 
 // 查询文档列表
-func DocumentList(bookId, userId string) []entity.Document {
-	if bookId == "" {
+func DocumentList(condition entity.DocumentListCondition, userId string) []entity.Document {
+	var documents []entity.Document
+	var err error
+	if len(condition.TagIds) > 0 {
+		// 按标签过滤时目录可为空
+		documents, err = dao.DocumentListByTag(middleware.Db, condition.BookId, condition.TagIds, userId)
+	} else if condition.BookId != "" {
+		documents, err = dao.DocumentList(middleware.Db, condition.BookId, userId)
+	} else {
 		return []entity.Document{}
 	}
-
-	documents, err := dao.DocumentList(middleware.Db, bookId, userId)
 	if err != nil {
 		panic(common.NewErr("查询失败", err))
 	}
@@ -308,6 +365,70 @@ func DocumentGetPublished(id string) entity.Document {
 	return document
 }
 
+// 将公开发布文档渲染为html
+func DocumentHtmlPublished(id string) entity.DocumentHtml {
+	document := DocumentGetPublished(id)
+	html, headings := util.RenderMarkdown(document.Content, common.PictureName, "/"+common.PictureName+"/")
+
+	toc := make([]entity.DocumentHeading, 0, len(headings))
+	for _, heading := range headings {
+		toc = append(toc, entity.DocumentHeading{Level: heading.Level, Id: heading.Id, Text: heading.Text})
+	}
+	return entity.DocumentHtml{
+		Id:         document.Id,
+		Name:       document.Name,
+		Html:       html,
+		Toc:        toc,
+		UpdateTime: document.UpdateTime,
+	}
+}
+
+// 生成最近发布文档的订阅源，baseUrl为服务地址，用于生成文档页面的绝对链接
+func DocumentFeed(condition entity.DocumentPageCondition, baseUrl string) util.Feed {
+	pageCondition := common.PageCondition[entity.DocumentPageCondition]{
+		Page:      common.Page{Current: 1, Size: 20},
+		Condition: entity.DocumentPageCondition{Username: condition.Username, BookName: condition.BookName},
+	}
+	records, _, err := dao.DocumentPagePulished(middleware.Db, pageCondition)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	title := "公开文档"
+	for _, name := range []string{condition.Username, condition.BookName} {
+		if name != "" {
+			title += " - " + name
+		}
+	}
+	feed := util.Feed{
+		Title:       title,
+		Link:        baseUrl + "/",
+		Description: title,
+		Items:       []util.FeedItem{},
+	}
+	for _, record := range records {
+		document := DocumentGetPublished(record.Id)
+		link := baseUrl + "/p/" + record.Id
+		feed.Items = append(feed.Items, util.FeedItem{
+			Id:        link,
+			Title:     record.Name,
+			Link:      link,
+			Summary:   util.MarkdownSummary(document.Content, 200),
+			Author:    record.Username,
+			Category:  record.BookName,
+			Published: time.UnixMilli(record.CreateTime),
+			Updated:   time.UnixMilli(record.UpdateTime),
+		})
+		if updated := time.UnixMilli(record.UpdateTime); updated.After(feed.Updated) {
+			feed.Updated = updated
+		}
+	}
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Now()
+	}
+	return feed
+}
+
 // 分页查询公开发布文档列表
 func DocumentPagePulished(pageCondition common.PageCondition[entity.DocumentPageCondition]) common.PageResult[entity.DocumentPageResult] {
 	records, total, err := dao.DocumentPagePulished(middleware.Db, pageCondition)
@@ -317,3 +438,41 @@ func DocumentPagePulished(pageCondition common.PageCondition[entity.DocumentPage
 	pageResult := common.PageResult[entity.DocumentPageResult]{Records: records, Total: total}
 	return pageResult
 }
+
+// 全文检索当前用户的文档
+func DocumentSearch(pageCondition common.PageCondition[entity.DocumentSearchCondition], userId string) common.PageResult[entity.DocumentSearchResult] {
+	// 拆分关键字并去重
+	keywords := []string{}
+	for _, keyword := range strings.Fields(pageCondition.Condition.Keyword) {
+		if !slices.Contains(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	if len(keywords) == 0 {
+		panic(common.NewError("搜索关键字不可为空"))
+	}
+	if len(keywords) > 10 {
+		panic(common.NewError("搜索关键字不可超过10个"))
+	}
+
+	records, total, err := dao.DocumentSearch(middleware.Db, keywords, pageCondition.Condition.TagIds, pageCondition.Page, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	for i := range records {
+		records[i].NameHighlight = util.Highlight(records[i].Name, keywords)
+		records[i].Snippet = util.HighlightSnippet(records[i].Content, keywords, 120)
+		// 内容为数据库截取的部分，截取位置之外仍有内容时补充省略号
+		if records[i].SnippetStart > 1 && !strings.HasPrefix(records[i].Snippet, "...") {
+			records[i].Snippet = "..." + records[i].Snippet
+		}
+		end := records[i].SnippetStart - 1 + utf8.RuneCountInString(records[i].Content)
+		if end < records[i].ContentLength && !strings.HasSuffix(records[i].Snippet, "...") {
+			records[i].Snippet += "..."
+		}
+	}
+
+	pageResult := common.PageResult[entity.DocumentSearchResult]{Records: records, Total: total}
+	return pageResult
+}
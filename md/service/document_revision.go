@@ -0,0 +1,105 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 查询文档历史版本列表
+func DocumentRevisionList(documentId, userId string) []entity.DocumentRevision {
+	// 校验文档归属
+	DocumentGet(documentId, userId)
+
+	revisions, err := dao.DocumentRevisionList(middleware.Db, documentId, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return revisions
+}
+
+// 查询文档历史版本
+func DocumentRevisionGet(id, userId string) entity.DocumentRevision {
+	revision, err := dao.DocumentRevisionGetById(middleware.Db, id, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return revision
+}
+
+// 比较文档两个版本的差异，版本id为空时使用文档当前内容
+func DocumentRevisionDiff(condition entity.DocumentRevisionCondition, userId string) entity.DocumentRevisionDiff {
+	if condition.FromId == "" && condition.ToId == "" {
+		panic(common.NewError("请选择需要比较的版本"))
+	}
+
+	fromName, fromContent := revisionContent(condition.FromId, condition.DocumentId, userId)
+	toName, toContent := revisionContent(condition.ToId, condition.DocumentId, userId)
+
+	lines := util.DiffLines(util.SplitLines(fromContent), util.SplitLines(toContent))
+	diff := entity.DocumentRevisionDiff{}
+	diff.Diff = util.FormatUnifiedDiff(fromName, toName, lines, 3)
+	for _, line := range lines {
+		switch line.Op {
+		case util.DiffInsert:
+			diff.Added++
+		case util.DiffDelete:
+			diff.Removed++
+		}
+	}
+	return diff
+}
+
+// 将文档恢复到指定历史版本，恢复前的内容会保存为新的历史版本
+func DocumentRevisionRestore(id, userId string) entity.Document {
+	revision := DocumentRevisionGet(id, userId)
+
+	document := entity.Document{}
+	document.Id = revision.DocumentId
+	document.Content = revision.Content
+	document.UserId = userId
+	document = DocumentUpdateContent(document)
+
+	middleware.Log.Infof("成功恢复文档历史版本: {%s}", id)
+	return document
+}
+
+// 查询版本名称及内容，版本id为空时返回文档当前内容
+func revisionContent(revisionId, documentId, userId string) (string, string) {
+	if revisionId == "" {
+		if documentId == "" {
+			panic(common.NewError("文档id不可为空"))
+		}
+		doc := DocumentGet(documentId, userId)
+		return "current", doc.Content
+	}
+
+	revision := DocumentRevisionGet(revisionId, userId)
+	if documentId != "" && revision.DocumentId != documentId {
+		panic(common.NewError("版本不属于该文档"))
+	}
+	return "revision/" + revision.Id, revision.Content
+}
+
+// 将文档当前内容保存为历史版本，并删除超出保留数量的历史版本
+func documentRevisionSave(tx *sqlx.Tx, doc entity.Document, userId string) error {
+	revision := entity.DocumentRevision{}
+	revision.Id = util.SnowflakeString()
+	revision.DocumentId = doc.Id
+	revision.Content = doc.Content
+	revision.CreateTime = doc.UpdateTime
+	revision.UserId = userId
+	err := dao.DocumentRevisionAdd(tx, revision)
+	if err != nil {
+		return err
+	}
+
+	if common.RevisionKeep > 0 {
+		return dao.DocumentRevisionDeleteOutdated(tx, doc.Id, common.RevisionKeep)
+	}
+	return nil
+}
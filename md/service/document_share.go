@@ -0,0 +1,164 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strings"
+	"time"
+)
+
+// 添加文档分享
+func DocumentShareAdd(condition entity.DocumentShareCondition, userId string) entity.DocumentShare {
+	// 校验文档归属
+	DocumentGet(condition.DocumentId, userId)
+
+	if condition.ExpireTime != 0 && condition.ExpireTime <= time.Now().UnixMilli() {
+		panic(common.NewError("过期时间不能早于当前时间"))
+	}
+
+	share := entity.DocumentShare{
+		Id:         util.SnowflakeString(),
+		Token:      randomToken(16),
+		DocumentId: condition.DocumentId,
+		ExpireTime: condition.ExpireTime,
+		CreateTime: time.Now().UnixMilli(),
+		UserId:     userId,
+	}
+	if condition.Password != "" {
+		share.Password = passwordHash(condition.Password)
+		share.HasPassword = true
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.DocumentShareAdd(tx, share)
+	if err != nil {
+		panic(common.NewErr("分享失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("分享失败", err))
+	}
+
+	middleware.Log.Infof("成功分享文档: {%s}", share.DocumentId)
+	return share
+}
+
+// 查询文档的分享列表
+func DocumentShareList(documentId, userId string) []entity.DocumentShare {
+	shares, err := dao.DocumentShareList(middleware.Db, documentId, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	for i := range shares {
+		shares[i].HasPassword = shares[i].Password != ""
+	}
+	return shares
+}
+
+// 撤销文档分享，撤销后链接无法访问
+func DocumentShareRevoke(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.DocumentShareRevoke(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("撤销失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("撤销失败", err))
+	}
+
+	middleware.Log.Infof("成功撤销文档分享: {%s}", id)
+}
+
+// 删除文档分享
+func DocumentShareDelete(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.DocumentShareDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	middleware.Log.Infof("成功删除文档分享: {%s}", id)
+}
+
+// 通过分享链接查看文档，校验是否撤销、过期及访问密码，访问密码错误按ip限制失败次数，与登录失败分开计数
+func DocumentShareView(token, password, ip string) entity.DocumentShareView {
+	share, err := dao.DocumentShareGetByToken(middleware.Db, strings.TrimSpace(token))
+	if err != nil || share.Revoked || (share.ExpireTime != 0 && share.ExpireTime <= time.Now().UnixMilli()) {
+		panic(common.NewErr("分享链接不存在或已失效", err))
+	}
+	if share.Password != "" {
+		if password == "" {
+			panic(common.NewErrorCode(common.HttpForbidden, "请输入访问密码"))
+		}
+		checkShareLock(ip)
+		match, rehash := util.VerifyPassword(share.Password, password, share.Id)
+		if !match {
+			shareFailed(ip)
+			panic(common.NewErrorCode(common.HttpForbidden, "访问密码错误"))
+		}
+		if rehash {
+			documentShareRehashPassword(share.Id, password)
+		}
+	}
+
+	document, err := dao.DocumentGetById(middleware.Db, share.DocumentId, share.UserId)
+	if err != nil {
+		panic(common.NewErr("分享链接不存在或已失效", err))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err = dao.DocumentShareIncrView(tx, share.Id)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	return entity.DocumentShareView{
+		Name:       document.Name,
+		Content:    document.Content,
+		Type:       document.Type,
+		UpdateTime: document.UpdateTime,
+		ViewCount:  share.ViewCount + 1,
+	}
+}
+
+// 使用当前算法和参数重新计算访问密码哈希，失败时不影响访问
+func documentShareRehashPassword(id, password string) {
+	err := catchError(func() {
+		tx := middleware.DbW.MustBegin()
+		defer tx.Rollback()
+
+		if err := dao.DocumentShareUpdatePassword(tx, id, passwordHash(password)); err != nil {
+			panic(err)
+		}
+		if err := tx.Commit(); err != nil {
+			panic(err)
+		}
+	})
+	if err != nil {
+		middleware.Log.Error("升级分享访问密码哈希失败：", err)
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"md/middleware"
+	"md/model/entity"
+	"testing"
+	"time"
+)
+
+func TestDocumentShareView(t *testing.T) {
+	userId := testUser(t)
+	bookId := testBook(t, userId, "", "分享")
+	documentId := testDocument(t, userId, bookId, "分享文档", "分享内容")
+	future := time.Now().Add(time.Hour).UnixMilli()
+
+	open := DocumentShareAdd(entity.DocumentShareCondition{DocumentId: documentId}, userId)
+	locked := DocumentShareAdd(entity.DocumentShareCondition{DocumentId: documentId, Password: "secret", ExpireTime: future}, userId)
+	expired := DocumentShareAdd(entity.DocumentShareCondition{DocumentId: documentId, ExpireTime: future}, userId)
+	revoked := DocumentShareAdd(entity.DocumentShareCondition{DocumentId: documentId}, userId)
+	if locked.Password == "secret" || !locked.HasPassword {
+		t.Fatal("访问密码应保存哈希")
+	}
+	middleware.DbW.MustExec(`update t_document_share set expire_time=$1 where id=$2`, time.Now().Add(-time.Minute).UnixMilli(), expired.Id)
+	DocumentShareRevoke(revoked.Id, userId)
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		message  string
+	}{
+		{"无密码", open.Token, "", ""},
+		{"无密码时忽略提交的密码", open.Token, "any", ""},
+		{"token前后空白", " " + open.Token + " ", "", ""},
+		{"未输入密码", locked.Token, "", "请输入访问密码"},
+		{"密码错误", locked.Token, "wrong", "访问密码错误"},
+		{"密码正确", locked.Token, "secret", ""},
+		{"已过期", expired.Token, "", "分享链接不存在或已失效"},
+		{"已撤销", revoked.Token, "", "分享链接不存在或已失效"},
+		{"不存在", "unknown", "", "分享链接不存在或已失效"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var view entity.DocumentShareView
+			message := testErrorMessage(func() {
+				view = DocumentShareView(tt.token, tt.password, "192.0.2.1")
+			})
+			if message != tt.message {
+				t.Fatalf("错误信息为 %q，期望 %q", message, tt.message)
+			}
+			if message == "" && (view.Name != "分享文档" || view.Content != "分享内容") {
+				t.Errorf("分享内容不正确: %+v", view)
+			}
+		})
+	}
+
+	// 每次成功访问增加访问次数
+	view := DocumentShareView(open.Token, "", "192.0.2.1")
+	if view.ViewCount != 4 {
+		t.Errorf("访问次数为 %d，期望 4", view.ViewCount)
+	}
+}
+
+func TestDocumentShareAdd(t *testing.T) {
+	userId := testUser(t)
+	bookId := testBook(t, userId, "", "分享")
+	documentId := testDocument(t, userId, bookId, "分享文档", "")
+
+	tests := []struct {
+		name      string
+		condition entity.DocumentShareCondition
+		userId    string
+		message   string
+	}{
+		{"永不过期", entity.DocumentShareCondition{DocumentId: documentId}, userId, ""},
+		{"过期时间早于当前时间", entity.DocumentShareCondition{DocumentId: documentId, ExpireTime: time.Now().Add(-time.Minute).UnixMilli()}, userId, "过期时间不能早于当前时间"},
+		{"其他用户的文档", entity.DocumentShareCondition{DocumentId: documentId}, testUser(t), "查询失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := testErrorMessage(func() {
+				DocumentShareAdd(tt.condition, tt.userId)
+			})
+			if message != tt.message {
+				t.Errorf("错误信息为 %q，期望 %q", message, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"archive/zip"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 导出压缩包
+type exportArchive struct {
+	zw       *zip.Writer
+	userId   string
+	pictures map[string]bool // 文档中引用的图片文件名
+}
+
+// 查询需要导出的目录，校验目录归属
+func BookExportCheck(id, userId string) entity.Book {
+	book := Book(id)
+	if book.UserId != userId {
+		panic(common.NewError("目录不存在"))
+	}
+	return book
+}
+
+// 导出目录及其子目录下的全部文档和引用的图片为zip
+func BookExport(w io.Writer, book entity.Book, userId string) error {
+	archive := newExportArchive(w, userId)
+	if err := archive.addBook(book, ""); err != nil {
+		return err
+	}
+	return archive.close()
+}
+
+// 导出当前用户全部目录、文档和引用的图片为zip
+func ExportAll(w io.Writer, userId string) error {
+	archive := newExportArchive(w, userId)
+
+	// 未归属目录的文档放在根目录
+	documents, err := dao.DocumentList(middleware.Db, "", userId)
+	if err != nil {
+		return err
+	}
+	for _, document := range documents {
+		if err := archive.addDocument(document.Id, ""); err != nil {
+			return err
+		}
+	}
+
+	for _, book := range BookList(userId) {
+		if book.ParentId != "" {
+			continue
+		}
+		if err := archive.addBook(book, ""); err != nil {
+			return err
+		}
+	}
+	return archive.close()
+}
+
+func newExportArchive(w io.Writer, userId string) *exportArchive {
+	return &exportArchive{
+		zw:       zip.NewWriter(w),
+		userId:   userId,
+		pictures: map[string]bool{},
+	}
+}
+
+// 添加目录，dir为上级目录在压缩包中的路径
+func (a *exportArchive) addBook(book entity.Book, dir string) error {
+	bookDir := path.Join(dir, util.SafeFileName(book.Name))
+	if _, err := a.zw.Create(bookDir + "/"); err != nil {
+		return err
+	}
+
+	documents, err := dao.DocumentList(middleware.Db, book.Id, a.userId)
+	if err != nil {
+		return err
+	}
+	for _, document := range documents {
+		if err := a.addDocument(document.Id, bookDir); err != nil {
+			return err
+		}
+	}
+
+	children, err := dao.BookByParentId(middleware.Db, a.userId, book.Id)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := a.addBook(child, bookDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 添加文档，图片链接改写为压缩包内的相对路径
+func (a *exportArchive) addDocument(id, dir string) error {
+	document, err := dao.DocumentGetById(middleware.Db, id, a.userId)
+	if err != nil {
+		return err
+	}
+
+	depth := 0
+	if dir != "" {
+		depth = strings.Count(dir, "/") + 1
+	}
+	prefix := strings.Repeat("../", depth) + common.PictureName + "/"
+	re := pictureLinkRegexp()
+	content := re.ReplaceAllStringFunc(document.Content, func(match string) string {
+		filename := re.FindStringSubmatch(match)[1]
+		a.pictures[filename] = true
+		return "](" + prefix + filename
+	})
+
+	writer, err := a.zw.Create(path.Join(dir, util.SafeFileName(document.Name)+entity.MdExt))
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write([]byte(content))
+	return err
+}
+
+// 写入引用的图片并结束压缩包
+func (a *exportArchive) close() error {
+	filenames := []string{}
+	for filename := range a.pictures {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filepath.Join(common.DataPath, common.ResourceName, common.PictureName, filename))
+		if err != nil {
+			middleware.Log.Warnf("导出图片不存在: {%s}", filename)
+			continue
+		}
+		writer, err := a.zw.Create(path.Join(common.PictureName, filename))
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+	}
+	return a.zw.Close()
+}
+
+// 匹配markdown中指向图片目录的链接，如 ](../picture/a.png)、](http://host/picture/a.png)，分组1为文件名
+func pictureLinkRegexp() *regexp.Regexp {
+	return regexp.MustCompile(`\]\(\s*(?:[^)\s]*/)?` + regexp.QuoteMeta(common.PictureName) + `/([^)\s"/]+\.[^)\s"/.]+)`)
+}
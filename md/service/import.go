@@ -0,0 +1,318 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 导入限制
+const (
+	ImportFileMaxSize  = 1000 * 1000 * 20  // 单个文件（包括压缩包中的文件）的最大字节数
+	ImportTotalMaxSize = 1000 * 1000 * 200 // 一次导入的上传文件及解压后文件的最大总字节数
+	importMaxFiles     = 2000              // 一次导入的最大文件数
+)
+
+// 导入的上传文件
+type ImportFile struct {
+	Name string
+	Data []byte
+}
+
+// 导入过程状态
+type importer struct {
+	userId   string
+	bookId   string                 // 根目录文档导入的目录，为空时根目录文档无法导入
+	files    map[string][]byte      // 可被文档引用的文件，路径 -> 内容
+	books    map[string]entity.Book // 已解析的目录，目录路径 -> 目录
+	pictures map[string]string      // 已上传的图片，文件路径 -> 图片文件名
+	size     int64                  // 已读取文件的总字节数
+	results  []entity.ImportResult
+}
+
+// 导入markdown文件或包含目录结构的zip压缩包
+// 压缩包中的文件夹创建为目录（已存在同名目录时复用），markdown中引用的本地图片上传到图片库
+func Import(files []ImportFile, bookId, userId string) []entity.ImportResult {
+	if len(files) == 0 {
+		panic(common.NewError("请选择需要导入的文件"))
+	}
+	if len(files) > importMaxFiles {
+		panic(common.NewError(fmt.Sprintf("文件数量不可超过%d个", importMaxFiles)))
+	}
+
+	im := &importer{
+		userId:   userId,
+		bookId:   bookId,
+		files:    map[string][]byte{},
+		books:    map[string]entity.Book{},
+		pictures: map[string]string{},
+		results:  []entity.ImportResult{},
+	}
+
+	// 导入目录需属于当前用户
+	if bookId != "" {
+		book := Book(bookId)
+		if book.UserId != userId {
+			panic(common.NewError("目录不存在"))
+		}
+		im.books["."] = book
+	}
+
+	// 收集文档及可引用的文件，单独上传的图片按文件名引用
+	documents := []string{}
+	for _, file := range files {
+		name := path.Base(strings.ReplaceAll(file.Name, "\\", "/"))
+		if util.FileExt(name) != ".zip" && len(file.Data) > ImportFileMaxSize {
+			im.result(file.Name, entity.ImportFailed, "文件大小不可超过20MB", "")
+			continue
+		}
+		switch util.FileExt(name) {
+		case entity.MdExt:
+			im.files[name] = file.Data
+			documents = append(documents, name)
+		case ".zip":
+			entries, err := im.readZip(file)
+			if err != nil {
+				im.result(file.Name, entity.ImportFailed, "压缩包解析失败："+err.Error(), "")
+				continue
+			}
+			documents = append(documents, entries...)
+		default:
+			if slices.Contains(pictureExtArr, util.FileExt(name)) {
+				im.files[name] = file.Data
+			} else {
+				im.result(file.Name, entity.ImportFailed, "不支持的文件类型", "")
+			}
+		}
+	}
+
+	sort.Strings(documents)
+	for _, documentPath := range documents {
+		im.importDocument(documentPath)
+	}
+
+	middleware.Log.Infof("导入完成: {%d}个文件", len(im.results))
+	return im.results
+}
+
+// 读取压缩包内的文件，返回其中的markdown文件路径
+func (im *importer) readZip(file ImportFile) ([]string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(file.Data), int64(len(file.Data)))
+	if err != nil {
+		return nil, err
+	}
+
+	documents := []string{}
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean(strings.ReplaceAll(f.Name, "\\", "/"))
+		if strings.HasPrefix(name, "../") || strings.HasPrefix(name, "/") || strings.HasPrefix(path.Base(name), ".") {
+			continue
+		}
+		if util.FileExt(name) != entity.MdExt && !slices.Contains(pictureExtArr, util.FileExt(name)) {
+			continue
+		}
+		if len(im.files) >= importMaxFiles {
+			return nil, fmt.Errorf("文件数量不可超过%d个", importMaxFiles)
+		}
+
+		// 压缩包中记录的文件大小可以伪造，按实际读取的字节数限制
+		if f.UncompressedSize64 > ImportFileMaxSize {
+			im.result(name, entity.ImportFailed, "文件大小不可超过20MB", "")
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, ImportFileMaxSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > ImportFileMaxSize {
+			im.result(name, entity.ImportFailed, "文件大小不可超过20MB", "")
+			continue
+		}
+		im.size += int64(len(data))
+		if im.size > ImportTotalMaxSize {
+			return nil, errors.New("解压后的文件总大小不可超过200MB")
+		}
+
+		im.files[name] = data
+		if util.FileExt(name) == entity.MdExt {
+			documents = append(documents, name)
+		}
+	}
+	return documents, nil
+}
+
+// 导入单个markdown文件
+func (im *importer) importDocument(documentPath string) {
+	err := catchError(func() {
+		name := strings.TrimSpace(strings.TrimSuffix(path.Base(documentPath), path.Ext(documentPath)))
+		dir := path.Dir(documentPath)
+
+		book, err := im.book(dir)
+		if err != nil {
+			im.result(documentPath, entity.ImportConflict, err.Error(), "")
+			return
+		}
+		if book.Id == "" {
+			im.result(documentPath, entity.ImportFailed, "请先选择导入的目录", "")
+			return
+		}
+
+		source := string(im.files[documentPath])
+		depth := bookDepth(book)
+
+		// 已存在同名文档时，内容相同则跳过，否则视为冲突，两种情况都不上传图片
+		docs, err := dao.DocumentGetName(middleware.Db, name, im.userId)
+		if err != nil {
+			panic(common.NewErr("查询失败", err))
+		}
+		if len(docs) > 0 {
+			if docs[0].BookId == book.Id && docs[0].Content == im.uploadPictures(source, dir, depth, false) {
+				im.result(documentPath, entity.ImportSkipped, "已存在相同文档", docs[0].Id)
+			} else {
+				im.result(documentPath, entity.ImportConflict, "已存在同名文档", docs[0].Id)
+			}
+			return
+		}
+
+		document := entity.Document{}
+		document.Name = name
+		document.Content = im.uploadPictures(source, dir, depth, true)
+		document.Type = entity.DocMd
+		document.BookId = book.Id
+		document.UserId = im.userId
+		document = DocumentAdd(document)
+		im.result(documentPath, entity.ImportCreated, "导入成功", document.Id)
+	})
+	if err != nil {
+		im.result(documentPath, entity.ImportFailed, err.Error(), "")
+	}
+}
+
+// 查询或创建文件夹对应的目录，同名目录位于其他上级目录下时返回error
+func (im *importer) book(dir string) (entity.Book, error) {
+	if book, ok := im.books[dir]; ok {
+		return book, nil
+	}
+	if dir == "." {
+		return entity.Book{}, nil
+	}
+
+	parent, err := im.book(path.Dir(dir))
+	if err != nil {
+		return parent, err
+	}
+
+	name := strings.TrimSpace(path.Base(dir))
+	tx := middleware.DbW.MustBegin()
+	books, err := dao.BookListByName(tx, name, im.userId)
+	tx.Rollback()
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	var book entity.Book
+	if len(books) > 0 {
+		if books[0].ParentId != parent.Id {
+			return book, errors.New("已存在同名目录：" + name)
+		}
+		book = books[0]
+	} else {
+		book = BookAdd(entity.Book{Name: name, ParentId: parent.Id, UserId: im.userId})
+	}
+
+	im.books[dir] = book
+	return book, nil
+}
+
+// 上传markdown中引用的本地图片，并将链接改写为图片目录下的相对路径
+// save为false时不上传，只计算改写后的内容，用于与已存在的文档比较
+func (im *importer) uploadPictures(content, dir string, depth int, save bool) string {
+	re := regexp.MustCompile(`(!\[[^\]]*\]\()\s*<?([^)\s>]+)>?`)
+	prefix := strings.Repeat("../", depth) + common.PictureName + "/"
+
+	return re.ReplaceAllStringFunc(content, func(match string) string {
+		groups := re.FindStringSubmatch(match)
+		target := groups[2]
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "/") || strings.HasPrefix(target, "data:") {
+			return match
+		}
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			target = unescaped
+		}
+
+		filePath := path.Clean(path.Join(dir, target))
+		if _, ok := im.files[filePath]; !ok {
+			// 单独上传的图片按文件名匹配
+			filePath = path.Base(target)
+		}
+		data, ok := im.files[filePath]
+		if !ok || !slices.Contains(pictureExtArr, util.FileExt(filePath)) {
+			return match
+		}
+
+		if !save {
+			_, filename, _ := pictureFilename(path.Base(filePath), int64(len(data)), util.EncryptSHA256(data), im.userId)
+			return groups[1] + prefix + filename
+		}
+
+		filename, ok := im.pictures[filePath]
+		if !ok {
+			err := catchError(func() {
+				picturePath, _ := pictureSave(path.Base(filePath), data, nil, im.userId)
+				filename = path.Base(picturePath)
+			})
+			if err != nil {
+				middleware.Log.Warnf("导入图片失败: {%s} %s", filePath, err)
+				return match
+			}
+			im.pictures[filePath] = filename
+		}
+		return groups[1] + prefix + filename
+	})
+}
+
+// 记录导入结果
+func (im *importer) result(path string, status entity.ImportStatus, message, documentId string) {
+	im.results = append(im.results, entity.ImportResult{
+		Path:       path,
+		Status:     status,
+		Message:    message,
+		DocumentId: documentId,
+	})
+}
+
+// 执行函数并将主动抛出的异常转换为error
+func catchError(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if errResponse, ok := r.(common.ErrorResponse); ok {
+				err = errors.New(errResponse.Message)
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	fn()
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/util"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kataras/golog"
+)
+
+// 测试使用临时数据目录中的sqlite数据库，各测试使用不同的用户区分数据
+func TestMain(m *testing.M) {
+	os.Exit(testMain(m))
+}
+
+func testMain(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "md-test-")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	middleware.Log = golog.New()
+	middleware.Log.SetLevel("disable")
+	common.DataPath = dir
+	common.PictureName = "picture"
+	common.ThumbnailName = "thumbnail"
+	common.TrashName = ".trash"
+
+	if err = util.InitSnowflake(0); err != nil {
+		panic(err)
+	}
+	if err = middleware.InitDataDir(common.DataPath, common.ResourceName, common.PictureName, common.ThumbnailName); err != nil {
+		panic(err)
+	}
+	if err = middleware.InitDB(); err != nil {
+		panic(err)
+	}
+	return m.Run()
+}
+
+// 添加测试用户，返回用户id
+func testUser(t *testing.T) string {
+	t.Helper()
+	id := util.SnowflakeString()
+	_, err := middleware.DbW.Exec(`insert into t_user (id,name,password,create_time) values ($1,$2,'',$3)`, id, "user"+id, time.Now().UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// 添加测试目录，返回目录id
+func testBook(t *testing.T, userId, parentId, name string) string {
+	t.Helper()
+	id := util.SnowflakeString()
+	_, err := middleware.DbW.Exec(`insert into t_book (id,parent_id,name,create_time,user_id) values ($1,$2,$3,$4,$5)`, id, parentId, name, time.Now().UnixMilli(), userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// 添加测试文档，返回文档id
+func testDocument(t *testing.T, userId, bookId, name, content string) string {
+	t.Helper()
+	id := util.SnowflakeString()
+	now := time.Now().UnixMilli()
+	_, err := middleware.DbW.Exec(`insert into t_document (id,name,content,type,published,create_time,update_time,book_id,user_id) values ($1,$2,$3,'md',false,$4,$4,$5,$6)`,
+		id, name, content, now, bookId, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// 执行函数并返回抛出的错误信息，未出错时返回空
+func testErrorMessage(fn func()) string {
+	if err := catchError(fn); err != nil {
+		return err.Error()
+	}
+	return ""
+}
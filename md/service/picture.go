@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"io"
 	"md/dao"
 	"md/middleware"
@@ -13,6 +14,10 @@ import (
 	"time"
 )
 
+// 支持的图片格式
+var pictureExtArr = []string{".apng", ".bmp", ".gif", ".ico", ".jfif", ".jpeg", ".jpg", ".png", ".webp"}
+var pictureExtNames = "APNG,BMP,GIF,ICO,JFIF,JPEG,JPG,PNG,WebP"
+
 // 分页查询图片记录
 func PicturePage(pageCondition common.PageCondition[interface{}], userId string) common.PageResult[entity.PicturePageResult] {
 	pictures, total, err := dao.PicturePage(middleware.Db, pageCondition.Page, userId)
@@ -36,7 +41,7 @@ func PicturePage(pageCondition common.PageCondition[interface{}], userId string)
 	return pageResult
 }
 
-// 删除图片
+// 删除图片，移入回收站
 func PictureDelete(id, userId string) {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
@@ -47,64 +52,65 @@ func PictureDelete(id, userId string) {
 		panic(common.NewErr("删除失败", err))
 	}
 
-	// 查询相同大小、相同hash的图片数量
-	countResult, err := dao.PictureCountBySizeHash(tx, picture.Size, picture.Hash)
+	// 查询引用同一文件的图片数量
+	countResult, err := dao.PictureCountByPath(tx, picture.Path)
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
 
-	// 删除记录
-	err = dao.PictureDeleteById(tx, id, userId)
+	// 移入回收站
+	err = dao.PictureSoftDelete(tx, id, time.Now().UnixMilli(), userId)
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
 
-	// 如果相同的图片只有一条记录，删除文件
-	if countResult.Count == 1 {
-		util.RemoveFile(filepath.Join(common.DataPath, common.ResourceName, common.PictureName), picture.Path)
-		util.RemoveFile(filepath.Join(common.DataPath, common.ResourceName, common.ThumbnailName), picture.Path)
-	}
-
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
 
+	// 如果文件只被这一条记录引用，将文件移入回收站目录
+	if countResult.Count == 1 {
+		util.MoveFile(picturePath(common.PictureName, picture.Path), trashPath(common.PictureName, picture.Path))
+		util.MoveFile(picturePath(common.ThumbnailName, picture.Path), trashPath(common.ThumbnailName, picture.Path))
+	}
+
 	middleware.Log.Infof("成功删除图片: {%s}", id)
 }
 
-// 图片上传
+// 生成缩略图，无法解码的格式（如ICO）在原图不超过100KB时直接作为缩略图
+func pictureThumbnail(pictureByte []byte) []byte {
+	thumbnailByte, err := util.GenerateThumbnail(pictureByte, 100, 100)
+	if err == nil {
+		return thumbnailByte
+	}
+	if errors.Is(err, util.ErrImageTooLarge) {
+		panic(common.NewError("图片尺寸过大，像素数不可超过4000万"))
+	}
+	if len(pictureByte) <= 1000*100 {
+		return pictureByte
+	}
+	panic(common.NewErr("缩略图生成失败，请上传缩略图", err))
+}
+
+// 图片上传，thumbnailFile、thumbnailInfo为nil时由服务端生成缩略图
 func PictureUpload(pictureFile, thumbnailFile multipart.File, pictureInfo, thumbnailInfo *multipart.FileHeader, userId string) (string, string) {
 	// 校验文件大小
 	if pictureInfo.Size == 0 {
 		panic(common.NewError("图片解析失败"))
 	}
-	if thumbnailInfo.Size == 0 {
-		panic(common.NewError("缩略图解析失败"))
-	}
 	if pictureInfo.Size > 1000*1000*20 {
 		panic(common.NewError("图片大小不可超过20MB"))
 	}
-	if thumbnailInfo.Size > 1000*100 {
-		panic(common.NewError("缩略图大小不可超过100KB"))
-	}
 
-	// 获取图片,略缩图后缀
+	// 获取图片后缀
 	pictureExt := util.FileExt(pictureInfo.Filename)
-	thumbnailExt := util.FileExt(thumbnailInfo.Filename)
-
-	extArr := []string{".apng", ".bmp", ".gif", ".ico", ".jfif", ".jpeg", ".jpg", ".png", ".webp"}
-	extNames := "APNG,BMP,GIF,ICO,JFIF,JPEG,JPG,PNG,WebP"
 
-	if !slices.Contains(extArr, pictureExt) {
-		panic(common.NewError("仅支持以下格式的图片：" + extNames))
+	if !slices.Contains(pictureExtArr, pictureExt) {
+		panic(common.NewError("仅支持以下格式的图片：" + pictureExtNames))
 	}
 
-	if !slices.Contains(extArr, thumbnailExt) {
-		panic(common.NewError("仅支持以下格式的缩略图：" + extNames))
-	}
-
-	if util.StringLength(pictureInfo.Filename) > 1000 || util.StringLength(thumbnailInfo.Filename) > 1000 {
+	if util.StringLength(pictureInfo.Filename) > 1000 {
 		panic(common.NewError("图片文件名称过长"))
 	}
 
@@ -114,27 +120,64 @@ func PictureUpload(pictureFile, thumbnailFile multipart.File, pictureInfo, thumb
 		panic(common.NewErr("图片解析失败", err))
 	}
 
-	thumbnailByte, err := io.ReadAll(thumbnailFile)
-	if err != nil {
-		panic(common.NewErr("缩略图解析失败", err))
-	}
+	// 读取客户端上传的缩略图
+	var thumbnailByte []byte
+	if thumbnailFile != nil && thumbnailInfo != nil {
+		if thumbnailInfo.Size == 0 {
+			panic(common.NewError("缩略图解析失败"))
+		}
+		if thumbnailInfo.Size > 1000*100 {
+			panic(common.NewError("缩略图大小不可超过100KB"))
+		}
+		if !slices.Contains(pictureExtArr, util.FileExt(thumbnailInfo.Filename)) {
+			panic(common.NewError("仅支持以下格式的缩略图：" + pictureExtNames))
+		}
+		if util.StringLength(thumbnailInfo.Filename) > 1000 {
+			panic(common.NewError("图片文件名称过长"))
+		}
+
+		thumbnailByte, err = io.ReadAll(thumbnailFile)
+		if err != nil {
+			panic(common.NewErr("缩略图解析失败", err))
+		}
+	}
+
+	return pictureSave(pictureInfo.Filename, pictureByte, thumbnailByte, userId)
+}
 
-	// 生成sha256校验码
+// 保存图片文件及记录，thumbnailByte为nil时由服务端生成缩略图，返回图片访问路径及提示信息
+func pictureSave(name string, pictureByte, thumbnailByte []byte, userId string) (string, string) {
+	size := int64(len(pictureByte))
 	sha256Str := util.EncryptSHA256(pictureByte)
+	samePictures, filename, exist := pictureFilename(name, size, sha256Str, userId)
 
-	// 生成文件名
-	filename := util.SnowflakeString() + pictureExt
-
-	// 保存文件
-	dirPath := filepath.Join(common.DataPath, common.ResourceName, common.PictureName)
-	if err := util.CreateFile(dirPath, filename, pictureByte); err != nil {
-		panic(common.NewErr("图片上传失败", err))
+	// 当前用户已上传过相同图片，直接返回
+	if exist {
+		path := "/" + filepath.ToSlash(filepath.Join(common.PictureName, filename))
+		middleware.Log.Infof("图片已存在: {%s}", path)
+		return path, "图片已存在"
 	}
 
-	// 保存缩略图
-	dirPath = filepath.Join(common.DataPath, common.ResourceName, common.ThumbnailName)
-	if err := util.CreateFile(dirPath, filename, thumbnailByte); err != nil {
-		panic(common.NewErr("图片上传失败", err))
+	if len(samePictures) > 0 {
+		// 复用的文件只被回收站中的图片引用时已移入回收站目录，移回
+		pictureFileRestore(filename)
+	} else {
+		// 客户端上传的缩略图优先，否则由服务端生成
+		if thumbnailByte == nil {
+			thumbnailByte = pictureThumbnail(pictureByte)
+		}
+
+		// 保存文件
+		dirPath := filepath.Join(common.DataPath, common.ResourceName, common.PictureName)
+		if err := util.CreateFile(dirPath, filename, pictureByte); err != nil {
+			panic(common.NewErr("图片上传失败", err))
+		}
+
+		// 保存缩略图
+		dirPath = filepath.Join(common.DataPath, common.ResourceName, common.ThumbnailName)
+		if err := util.CreateFile(dirPath, filename, thumbnailByte); err != nil {
+			panic(common.NewErr("图片上传失败", err))
+		}
 	}
 
 	// 添加记录
@@ -144,12 +187,12 @@ func PictureUpload(pictureFile, thumbnailFile multipart.File, pictureInfo, thumb
 	picture := entity.Picture{}
 	picture.Id = util.SnowflakeString()
 	picture.CreateTime = time.Now().UnixMilli()
-	picture.Name = pictureInfo.Filename
+	picture.Name = name
 	picture.Path = filename
 	picture.Hash = sha256Str
-	picture.Size = pictureInfo.Size
+	picture.Size = size
 	picture.UserId = userId
-	err = dao.PictureAdd(tx, picture)
+	err := dao.PictureAdd(tx, picture)
 	if err != nil {
 		panic(common.NewErr("图片上传失败", err))
 	}
@@ -163,3 +206,37 @@ func PictureUpload(pictureFile, thumbnailFile multipart.File, pictureInfo, thumb
 	middleware.Log.Infof("成功上传图片: {%s}", path)
 	return path, "上传成功"
 }
+
+// 查询相同大小、相同hash的图片（包含回收站中的图片），相同内容只保存一份文件
+// 返回相同内容的图片记录和保存使用的文件名，以及当前用户是否已上传过相同图片
+func pictureFilename(name string, size int64, sha256Str, userId string) ([]entity.Picture, string, bool) {
+	samePictures, err := dao.PictureBySizeHash(middleware.Db, size, sha256Str)
+	if err != nil {
+		panic(common.NewErr("图片上传失败", err))
+	}
+	for _, v := range samePictures {
+		if v.UserId == userId && v.DeletedTime == 0 {
+			return samePictures, v.Path, true
+		}
+	}
+	if len(samePictures) > 0 {
+		// 复用已存在的文件
+		return samePictures, samePictures[0].Path, false
+	}
+	// 以hash作为文件名
+	return samePictures, sha256Str + util.FileExt(name), false
+}
+
+// 图片或缩略图文件的路径
+func picturePath(dirName, path string) string {
+	return filepath.Join(common.DataPath, common.ResourceName, dirName, path)
+}
+
+// 图片和缩略图文件已移入回收站目录时移回
+func pictureFileRestore(path string) {
+	for _, dirName := range []string{common.PictureName, common.ThumbnailName} {
+		if !util.IsDirExist(picturePath(dirName, path)) {
+			util.MoveFile(trashPath(dirName, path), picturePath(dirName, path))
+		}
+	}
+}
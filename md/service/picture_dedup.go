@@ -0,0 +1,167 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 合并相同内容的图片文件，用于按hash保存图片之前上传的图片
+// 相同大小、相同hash的图片改为引用同一文件，并替换文档和历史版本中的图片链接，返回合并的文件数量
+func PictureDedup() (int, error) {
+	pictures, err := dao.PictureListAll(middleware.Db)
+	if err != nil {
+		return 0, err
+	}
+
+	// 按大小和hash分组，保持上传顺序
+	keys := []string{}
+	groups := map[string][]entity.Picture{}
+	for _, picture := range pictures {
+		key := picture.Hash + "-" + strconv.FormatInt(picture.Size, 10)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], picture)
+	}
+
+	// 旧文件名 -> 保留的文件名
+	replaces := map[string]string{}
+	for _, key := range keys {
+		path, active := pictureDedupPath(groups[key])
+		if path == "" {
+			continue
+		}
+		if active {
+			pictureFileRestore(path)
+		}
+		for _, picture := range groups[key] {
+			if picture.Path != path {
+				replaces[picture.Path] = path
+			}
+		}
+	}
+	if len(replaces) == 0 {
+		return 0, nil
+	}
+
+	bookDirs, err := pictureDedupBookDirs()
+	if err != nil {
+		return 0, err
+	}
+
+	// 查询引用了被合并文件的文档并替换链接，只替换文件名，内容不变，不保存历史版本
+	documents := map[string]entity.Document{}
+	for oldPath, newPath := range replaces {
+		oldLink := common.PictureName + "/" + oldPath
+		list, err := dao.DocumentListByContent(middleware.Db, oldLink)
+		if err != nil {
+			return 0, err
+		}
+		for _, document := range list {
+			// 同一文档引用多个被合并的图片时，使用已替换的内容
+			if replaced, ok := documents[document.Id]; ok {
+				document = replaced
+			}
+			document.Content = strings.ReplaceAll(document.Content, oldLink, common.PictureName+"/"+newPath)
+			documents[document.Id] = document
+		}
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	for oldPath, newPath := range replaces {
+		if err = dao.PictureUpdatePath(tx, oldPath, newPath); err != nil {
+			return 0, err
+		}
+		err = dao.DocumentRevisionReplaceContent(tx, common.PictureName+"/"+oldPath, common.PictureName+"/"+newPath)
+		if err != nil {
+			return 0, err
+		}
+	}
+	// 需要重写的md文件路径和内容
+	files := map[string]string{}
+	for _, document := range documents {
+		document.UpdateTime = time.Now().UnixMilli()
+		if err = dao.DocumentUpdateContent(tx, document); err != nil {
+			return 0, err
+		}
+		if filePath := pictureDedupFilePath(document, bookDirs); filePath != "" {
+			files[filePath] = document.Content
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	for filePath, content := range files {
+		if util.IsDirExist(filePath) {
+			util.CreateFile(filepath.Dir(filePath), filepath.Base(filePath), []byte(content))
+		}
+	}
+	for oldPath := range replaces {
+		for _, dirName := range []string{common.PictureName, common.ThumbnailName} {
+			util.RemoveDir(picturePath(dirName, oldPath))
+			util.RemoveDir(trashPath(dirName, oldPath))
+		}
+		middleware.Log.Infof("合并图片: {%s -> %s}", oldPath, replaces[oldPath])
+	}
+	return len(replaces), nil
+}
+
+// 选择同一内容的图片保留的文件，优先使用未删除的图片引用的文件，文件都不存在时返回空
+// 同时返回是否有未删除的图片，有时文件需在图片目录中
+func pictureDedupPath(pictures []entity.Picture) (string, bool) {
+	active := false
+	for _, picture := range pictures {
+		if picture.DeletedTime == 0 {
+			active = true
+		}
+	}
+	path := ""
+	for _, picture := range pictures {
+		if !util.IsDirExist(picturePath(common.PictureName, picture.Path)) && !util.IsDirExist(trashPath(common.PictureName, picture.Path)) {
+			continue
+		}
+		if picture.DeletedTime == 0 {
+			return picture.Path, active
+		}
+		if path == "" {
+			path = picture.Path
+		}
+	}
+	return path, active
+}
+
+// 全部目录在数据目录中的路径
+func pictureDedupBookDirs() (map[string]string, error) {
+	books, err := dao.BookListAll(middleware.Db)
+	if err != nil {
+		return nil, err
+	}
+	bookDirs := map[string]string{"": bookDirPath(entity.Book{})}
+	for _, book := range books {
+		bookDirs[book.Id] = bookDirPath(book)
+	}
+	return bookDirs, nil
+}
+
+// 文档对应的md文件路径，回收站中的文档为回收站目录中的文件，无法确定时返回空
+func pictureDedupFilePath(document entity.Document, bookDirs map[string]string) string {
+	if document.DeletedTime > 0 {
+		return trashPath(string(entity.TrashDocument), document.Id+entity.MdExt)
+	}
+	if dirPath, ok := bookDirs[document.BookId]; ok {
+		return filepath.Join(dirPath, document.Name+entity.MdExt)
+	}
+	return ""
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"time"
+)
+
+// 登录失败次数限制
+const (
+	signInAccountLimit = 5              // 同一用户名允许连续失败的次数
+	signInIpLimit      = 20             // 同一ip允许连续失败的次数
+	signInLockBase     = time.Minute    // 达到限制时的锁定时长，之后每次失败翻倍
+	signInLockMax      = 24 * time.Hour // 最长锁定时长
+	signInWindow       = 24 * time.Hour // 超过该时间没有失败且未锁定时重新计数
+	shareIpLimit       = 20             // 同一ip允许连续输错分享链接访问密码的次数
+)
+
+// 各类型允许连续失败的次数
+var signInLimits = map[entity.SignInAttemptType]int64{
+	entity.SignInAttemptAccount: signInAccountLimit,
+	entity.SignInAttemptIp:      signInIpLimit,
+	entity.SignInAttemptShare:   shareIpLimit,
+}
+
+// 查询锁定中的用户名和ip
+func SignInLockList() []entity.SignInAttempt {
+	attempts, err := dao.SignInAttemptListLocked(middleware.Db, time.Now().UnixMilli())
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return attempts
+}
+
+// 解除用户名或ip的锁定，同时清空失败次数
+func SignInUnlock(condition entity.SignInAttemptCondition) {
+	if _, ok := signInLimits[condition.Type]; !ok {
+		panic(common.NewError("不支持的类型"))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.SignInAttemptDelete(tx, condition.Type, condition.Name)
+	if err != nil {
+		panic(common.NewErr("解除锁定失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("解除锁定失败", err))
+	}
+
+	middleware.Log.Infof("解除登录锁定: {%s %s}", condition.Type, condition.Name)
+}
+
+// 校验用户名和ip是否被锁定，锁定时抛出异常
+func checkSignInLock(name, ip string) {
+	if minutes := attemptLockedMinutes(signInAttemptNames(name, ip)); minutes > 0 {
+		panic(common.NewErrorCode(common.HttpTooMany, fmt.Sprintf("登录失败次数过多，请于%d分钟后再试", minutes)))
+	}
+}
+
+// 校验ip是否因分享链接访问密码错误被锁定，与登录失败分开计数，锁定时抛出异常
+func checkShareLock(ip string) {
+	if minutes := attemptLockedMinutes(shareAttemptNames(ip)); minutes > 0 {
+		panic(common.NewErrorCode(common.HttpTooMany, fmt.Sprintf("访问密码错误次数过多，请于%d分钟后再试", minutes)))
+	}
+}
+
+// 查询剩余的锁定分钟数，未锁定时返回0
+func attemptLockedMinutes(names map[entity.SignInAttemptType]string) int64 {
+	now := time.Now().UnixMilli()
+	for attemptType, attemptName := range names {
+		attempt, err := dao.SignInAttemptGet(middleware.Db, attemptType, attemptName)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				middleware.Log.Error("查询登录失败记录失败：", err)
+			}
+			continue
+		}
+		if attempt.LockedUntil > now {
+			return (attempt.LockedUntil-now)/time.Minute.Milliseconds() + 1
+		}
+	}
+	return 0
+}
+
+// 记录一次登录失败
+func signInFailed(name, ip string) {
+	attemptFailed(signInAttemptNames(name, ip))
+}
+
+// 记录一次分享链接访问密码错误
+func shareFailed(ip string) {
+	attemptFailed(shareAttemptNames(ip))
+}
+
+// 记录一次失败，达到限制时按失败次数指数增加锁定时长，失败时只记录日志
+func attemptFailed(names map[entity.SignInAttemptType]string) {
+	err := catchError(func() {
+		now := time.Now()
+		tx := middleware.DbW.MustBegin()
+		defer tx.Rollback()
+
+		// 顺带清理过期记录
+		err := dao.SignInAttemptDeleteExpired(tx, now.Add(-signInWindow).UnixMilli())
+		if err != nil {
+			panic(err)
+		}
+
+		for attemptType, attemptName := range names {
+			failedCount, err := dao.SignInAttemptIncrease(tx, attemptType, attemptName, now.UnixMilli())
+			if err != nil {
+				panic(err)
+			}
+			if limit := signInLimits[attemptType]; failedCount >= limit {
+				lockedUntil := now.Add(signInLockDuration(failedCount - limit)).UnixMilli()
+				if err = dao.SignInAttemptLock(tx, attemptType, attemptName, lockedUntil); err != nil {
+					panic(err)
+				}
+				middleware.Log.Warnf("失败次数过多，锁定: {%s %s} %d次", attemptType, attemptName, failedCount)
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			panic(err)
+		}
+	})
+	if err != nil {
+		middleware.Log.Error("记录登录失败次数失败：", err)
+	}
+}
+
+// 登录成功后清空用户名的失败次数，ip的失败次数不清空，避免用一个已知账号重置对其他账号的猜测
+func signInSucceeded(name string) {
+	err := catchError(func() {
+		tx := middleware.DbW.MustBegin()
+		defer tx.Rollback()
+
+		if err := dao.SignInAttemptDelete(tx, entity.SignInAttemptAccount, name); err != nil {
+			panic(err)
+		}
+		if err := tx.Commit(); err != nil {
+			panic(err)
+		}
+	})
+	if err != nil {
+		middleware.Log.Error("清空登录失败次数失败：", err)
+	}
+}
+
+// 登录失败记录的类型与名称，name为空时不按用户名限制，ip为空时不按ip限制
+func signInAttemptNames(name, ip string) map[entity.SignInAttemptType]string {
+	names := map[entity.SignInAttemptType]string{}
+	if name != "" {
+		names[entity.SignInAttemptAccount] = name
+	}
+	if ip != "" {
+		names[entity.SignInAttemptIp] = ip
+	}
+	return names
+}
+
+// 分享链接访问密码失败记录的类型与名称
+func shareAttemptNames(ip string) map[entity.SignInAttemptType]string {
+	names := map[entity.SignInAttemptType]string{}
+	if ip != "" {
+		names[entity.SignInAttemptShare] = ip
+	}
+	return names
+}
+
+// 超出限制over次后的锁定时长：signInLockBase * 2^over，不超过signInLockMax
+func signInLockDuration(over int64) time.Duration {
+	duration := signInLockBase
+	for i := int64(0); i < over && duration < signInLockMax; i++ {
+		duration *= 2
+	}
+	if duration > signInLockMax {
+		duration = signInLockMax
+	}
+	return duration
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 静态站点文件写入，name为站点内以/分隔的相对路径
+type siteWriteFunc func(name string, data []byte) error
+
+// 静态站点生成过程状态
+type siteExporter struct {
+	write     siteWriteFunc
+	baseUrl   string
+	books     map[string]entity.Book
+	used      map[string]bool           // 包含公开文档的目录，含上级目录
+	documents map[string][]siteDocument // 目录下的公开文档，key为目录id，根目录为空
+	pictures  map[string]bool           // 文档中引用的图片文件名
+	sitemap   []util.SitemapUrl
+	feedItems []util.FeedItem
+	published []entity.DocumentPageResult
+}
+
+// 导出全部公开发布文档为静态站点目录
+func ExportSiteDir(out, baseUrl string) error {
+	return exportSite(func(name string, data []byte) error {
+		filePath := filepath.Join(out, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filePath, data, 0644)
+	}, baseUrl)
+}
+
+// 导出全部公开发布文档为静态站点zip
+func ExportSite(w io.Writer, baseUrl string) error {
+	zw := zip.NewWriter(w)
+	err := exportSite(func(name string, data []byte) error {
+		writer, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	}, baseUrl)
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// 生成首页、目录页、文档页、图片、sitemap.xml和rss.xml
+// baseUrl为站点部署地址，用于sitemap和rss中的绝对链接
+func exportSite(write siteWriteFunc, baseUrl string) error {
+	s := &siteExporter{
+		write:     write,
+		baseUrl:   strings.TrimSuffix(strings.TrimSpace(baseUrl), "/"),
+		books:     map[string]entity.Book{},
+		used:      map[string]bool{},
+		documents: map[string][]siteDocument{},
+		pictures:  map[string]bool{},
+	}
+
+	published, _, err := dao.DocumentPagePulished(middleware.Db, common.PageCondition[entity.DocumentPageCondition]{})
+	if err != nil {
+		return err
+	}
+	s.published = published
+
+	books, err := dao.BookListAll(middleware.Db)
+	if err != nil {
+		return err
+	}
+	for _, book := range books {
+		s.books[book.Id] = book
+	}
+
+	steps := []func() error{s.writeDocuments, s.writeBooks, s.writeIndex, s.writePictures, s.writeSitemap, s.writeFeed}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+
+	middleware.Log.Infof("导出静态站点: 文档%d篇，图片%d张", len(s.published), len(s.pictures))
+	return nil
+}
+
+// 生成文档页
+func (s *siteExporter) writeDocuments() error {
+	re := pictureLinkRegexp()
+	for _, record := range s.published {
+		document, err := dao.DocumentGetPublished(middleware.Db, record.Id)
+		if err != nil {
+			return err
+		}
+
+		// 图片统一指向站点下的图片目录
+		content := re.ReplaceAllStringFunc(document.Content, func(match string) string {
+			filename := re.FindStringSubmatch(match)[1]
+			s.pictures[filename] = true
+			return "](../" + common.PictureName + "/" + filename
+		})
+		html, headings := util.RenderMarkdown(content, common.PictureName, "../"+common.PictureName+"/")
+		documentHtml := entity.DocumentHtml{Id: document.Id, Name: document.Name, Html: html, UpdateTime: document.UpdateTime}
+		for _, heading := range headings {
+			documentHtml.Toc = append(documentHtml.Toc, entity.DocumentHeading{Level: heading.Level, Id: heading.Id, Text: heading.Text})
+		}
+
+		page := sitePage{
+			Title:      document.Name,
+			Home:       "../index.html",
+			Breadcrumb: s.breadcrumb(record.BookId),
+			Document:   &documentHtml,
+		}
+		if err := s.writePage("doc/"+document.Id+".html", "document", page); err != nil {
+			return err
+		}
+
+		summary := util.MarkdownSummary(document.Content, 200)
+		s.documents[record.BookId] = append(s.documents[record.BookId], siteDocument{
+			Name:       document.Name,
+			Href:       "doc/" + document.Id + ".html",
+			Summary:    summary,
+			Username:   record.Username,
+			CreateTime: record.CreateTime,
+		})
+		for _, book := range s.ancestors(record.BookId) {
+			s.used[book.Id] = true
+		}
+
+		link := s.url("doc/" + document.Id + ".html")
+		s.sitemap = append(s.sitemap, util.SitemapUrl{Loc: link, LastMod: time.UnixMilli(document.UpdateTime).Format("2006-01-02")})
+		s.feedItems = append(s.feedItems, util.FeedItem{
+			Id:        link,
+			Title:     document.Name,
+			Link:      link,
+			Summary:   summary,
+			Author:    record.Username,
+			Category:  record.BookName,
+			Published: time.UnixMilli(record.CreateTime),
+			Updated:   time.UnixMilli(record.UpdateTime),
+		})
+	}
+	return nil
+}
+
+// 生成包含公开文档的目录页
+func (s *siteExporter) writeBooks() error {
+	for _, id := range sortedKeys(s.used) {
+		book := s.books[id]
+		page := sitePage{
+			Title:      book.Name,
+			Home:       "../index.html",
+			Breadcrumb: s.breadcrumb(book.ParentId),
+			Books:      s.childBooks(book.Id, "../"),
+			Documents:  s.documentLinks(book.Id, "../"),
+		}
+		if err := s.writePage("book/"+book.Id+".html", "book", page); err != nil {
+			return err
+		}
+		s.sitemap = append(s.sitemap, util.SitemapUrl{Loc: s.url("book/" + book.Id + ".html")})
+	}
+	return nil
+}
+
+// 生成首页，列出一级目录和全部公开文档
+func (s *siteExporter) writeIndex() error {
+	documents := []siteDocument{}
+	for _, bookDocuments := range s.documents {
+		documents = append(documents, bookDocuments...)
+	}
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].CreateTime > documents[j].CreateTime
+	})
+
+	page := sitePage{
+		Title:     "公开文档",
+		Books:     s.childBooks("", ""),
+		Documents: documents,
+	}
+	s.sitemap = append([]util.SitemapUrl{{Loc: s.url("index.html")}}, s.sitemap...)
+	return s.writePage("index.html", "index", page)
+}
+
+// 复制文档中引用的图片
+func (s *siteExporter) writePictures() error {
+	for _, filename := range sortedKeys(s.pictures) {
+		data, err := os.ReadFile(filepath.Join(common.DataPath, common.ResourceName, common.PictureName, filename))
+		if err != nil {
+			middleware.Log.Warnf("导出图片不存在: {%s}", filename)
+			continue
+		}
+		if err := s.write(common.PictureName+"/"+filename, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *siteExporter) writeSitemap() error {
+	data, err := util.Sitemap(s.sitemap)
+	if err != nil {
+		return err
+	}
+	return s.write("sitemap.xml", data)
+}
+
+// 生成最近20篇文档的rss订阅
+func (s *siteExporter) writeFeed() error {
+	feed := util.Feed{
+		Title:       "公开文档",
+		Link:        s.url("index.html"),
+		FeedLink:    s.url("rss.xml"),
+		Description: "公开文档",
+		Updated:     time.Now(),
+		Items:       s.feedItems,
+	}
+	sort.SliceStable(feed.Items, func(i, j int) bool {
+		return feed.Items[i].Published.After(feed.Items[j].Published)
+	})
+	if len(feed.Items) > 20 {
+		feed.Items = feed.Items[:20]
+	}
+	data, err := util.FeedRSS(feed)
+	if err != nil {
+		return err
+	}
+	return s.write("rss.xml", data)
+}
+
+func (s *siteExporter) writePage(name, templateName string, page sitePage) error {
+	var buf bytes.Buffer
+	if err := renderSitePage(&buf, templateName, page); err != nil {
+		return err
+	}
+	return s.write(name, buf.Bytes())
+}
+
+// 查询目录及其全部上级目录，按由上到下排序
+func (s *siteExporter) ancestors(bookId string) []entity.Book {
+	books := []entity.Book{}
+	for book, ok := s.books[bookId]; ok && len(books) < 100; book, ok = s.books[book.ParentId] {
+		books = append([]entity.Book{book}, books...)
+	}
+	return books
+}
+
+// 生成子目录页面中的上级目录导航
+func (s *siteExporter) breadcrumb(bookId string) []siteLink {
+	links := []siteLink{}
+	for _, book := range s.ancestors(bookId) {
+		links = append(links, siteLink{Name: book.Name, Href: "../book/" + book.Id + ".html"})
+	}
+	return links
+}
+
+// 查询包含公开文档的下级目录
+func (s *siteExporter) childBooks(parentId, prefix string) []siteLink {
+	links := []siteLink{}
+	for _, id := range sortedKeys(s.used) {
+		book := s.books[id]
+		if book.ParentId == parentId {
+			links = append(links, siteLink{Name: book.Name, Href: prefix + "book/" + book.Id + ".html"})
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].Name < links[j].Name
+	})
+	return links
+}
+
+// 目录下的公开文档，链接加上相对站点根目录的前缀
+func (s *siteExporter) documentLinks(bookId, prefix string) []siteDocument {
+	documents := []siteDocument{}
+	for _, document := range s.documents[bookId] {
+		document.Href = prefix + document.Href
+		documents = append(documents, document)
+	}
+	return documents
+}
+
+// 站点内路径对应的绝对地址，未设置站点地址时返回以/开头的路径
+func (s *siteExporter) url(name string) string {
+	return s.baseUrl + "/" + name
+}
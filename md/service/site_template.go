@@ -0,0 +1,116 @@
+package service
+
+import (
+	"html/template"
+	"io"
+	"md/model/entity"
+	"strings"
+	"time"
+)
+
+// 页面中的链接
+type siteLink struct {
+	Name string
+	Href string
+}
+
+// 页面中的文档列表项
+type siteDocument struct {
+	Name       string
+	Href       string
+	Summary    string
+	Username   string
+	CreateTime int64
+}
+
+// 页面数据
+type sitePage struct {
+	Title      string
+	Home       string     // 首页地址，为空时不显示导航
+	Breadcrumb []siteLink // 上级目录
+	Document   *entity.DocumentHtml
+	Books      []siteLink
+	Documents  []siteDocument
+}
+
+// 公开文档页面模板，index、book、document分别为首页、目录页、文档页
+var siteTemplate = template.Must(template.New("site").Funcs(template.FuncMap{
+	"safe":   func(s string) template.HTML { return template.HTML(s) },
+	"indent": func(level int) string { return strings.Repeat("  ", level-1) },
+	"date":   func(stamp int64) string { return time.UnixMilli(stamp).Format("2006-01-02") },
+}).Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{margin:0;color:#24292f;font:16px/1.7 -apple-system,"Segoe UI","PingFang SC","Microsoft YaHei",sans-serif}
+a{color:#0969da;text-decoration:none}
+header{max-width:1200px;margin:0 auto;padding:16px 24px 0;font-size:14px;color:#57606a}
+header a+a:before,header span:before{content:" / ";color:#8c959f}
+.page{display:flex;gap:32px;max-width:1200px;margin:0 auto;padding:32px 24px}
+.toc{flex:0 0 240px;position:sticky;top:32px;align-self:flex-start;max-height:calc(100vh - 64px);overflow:auto;font-size:14px}
+.toc a{display:block;color:#57606a;white-space:pre;overflow:hidden;text-overflow:ellipsis}
+.toc a:hover{color:#0969da}
+main,article{flex:1;min-width:0}
+article img{max-width:100%}
+article pre{padding:16px;overflow:auto;background:#f6f8fa;border-radius:6px}
+article code{font-family:ui-monospace,SFMono-Regular,Consolas,monospace}
+article table{border-collapse:collapse}
+article th,article td{padding:6px 13px;border:1px solid #d0d7de}
+article blockquote{margin:0;padding:0 1em;color:#57606a;border-left:4px solid #d0d7de}
+ul.list{padding:0;list-style:none}
+ul.list li{padding:8px 0;border-bottom:1px solid #eaeef2}
+.meta{font-size:13px;color:#8c959f}
+@media (max-width:800px){.toc{display:none}}
+</style>
+</head>
+<body>
+{{if .Home}}<header><a href="{{.Home}}">首页</a>{{range .Breadcrumb}}<a href="{{.Href}}">{{.Name}}</a>{{end}}</header>{{end}}
+{{end}}
+
+{{define "list"}}{{if .Books}}<h2>目录</h2>
+<ul class="list">{{range .Books}}<li><a href="{{.Href}}">{{.Name}}</a></li>{{end}}</ul>{{end}}
+{{if .Documents}}<h2>文档</h2>
+<ul class="list">{{range .Documents}}<li><a href="{{.Href}}">{{.Name}}</a> <span class="meta">{{date .CreateTime}}{{if .Username}} · {{.Username}}{{end}}</span>{{if .Summary}}<div class="meta">{{.Summary}}</div>{{end}}</li>{{end}}</ul>{{end}}
+{{end}}
+
+{{define "index"}}{{template "head" .}}<div class="page"><main>
+<h1>{{.Title}}</h1>
+{{template "list" .}}
+</main></div>
+</body>
+</html>
+{{end}}
+
+{{define "book"}}{{template "head" .}}<div class="page"><main>
+<h1>{{.Title}}</h1>
+{{template "list" .}}
+</main></div>
+</body>
+</html>
+{{end}}
+
+{{define "document"}}{{template "head" .}}<div class="page">
+{{with .Document}}{{if .Toc}}<nav class="toc">{{range .Toc}}<a href="#{{.Id}}">{{indent .Level}}{{.Text}}</a>{{end}}</nav>{{end}}
+<article>
+<h1>{{.Name}}</h1>
+<div class="meta">{{date .UpdateTime}}</div>
+{{safe .Html}}
+</article>{{end}}
+</div>
+</body>
+</html>
+{{end}}`))
+
+// 使用模板输出页面
+func renderSitePage(w io.Writer, name string, page sitePage) error {
+	return siteTemplate.ExecuteTemplate(w, name, page)
+}
+
+// 输出公开发布文档页面
+func DocumentPublishedPage(w io.Writer, id string) error {
+	document := DocumentHtmlPublished(id)
+	return renderSitePage(w, "document", sitePage{Title: document.Name, Document: &document})
+}
@@ -0,0 +1,552 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 同步过程状态，路径均为数据目录下以/分隔的相对路径
+type syncer struct {
+	user      entity.User
+	root      string
+	lastSync  int64
+	synced    map[string]bool            // 上次同步后两边都存在的路径
+	books     map[string]entity.Book     // 当前用户的目录
+	documents map[string]entity.Document // 当前用户的文档
+	others    map[string]bool            // 其他用户的目录、文档
+	dirs      map[string]fs.FileInfo     // 磁盘上的文件夹
+	files     map[string]fs.FileInfo     // 磁盘上的md文件
+	actions   []entity.SyncAction
+}
+
+// 对比数据目录与数据库，生成同步计划，不做任何修改
+func SyncPlan(userId string) entity.SyncReport {
+	s := newSyncer(userId)
+	s.plan()
+	return s.report(true, 0)
+}
+
+// 对比数据目录与数据库并执行同步
+// 两边都存在时以内容hash判断是否变化，较新的一方覆盖另一方；只存在于一方时，上次同步后两边都存在的视为已删除，否则视为新增
+func SyncApply(userId string) entity.SyncReport {
+	s := newSyncer(userId)
+	s.plan()
+	s.applyDb()
+	s.applyFile()
+
+	// 记录同步时间及两边都存在的路径
+	syncTime := time.Now().UnixMilli()
+	paths := newSyncer(userId).syncedPaths()
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+	err := dao.SyncTimeSave(tx, userId, syncTime)
+	if err != nil {
+		panic(common.NewErr("同步失败", err))
+	}
+	err = dao.SyncPathSave(tx, userId, paths)
+	if err != nil {
+		panic(common.NewErr("同步失败", err))
+	}
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("同步失败", err))
+	}
+
+	middleware.Log.Infof("同步完成: {%s} %d项", s.user.Name, len(s.actions))
+	return s.report(false, syncTime)
+}
+
+// 命令行模式同步指定用户，mode为plan或apply
+func SyncCommand(mode, userName string) error {
+	if mode != "plan" && mode != "apply" {
+		return errors.New("不支持的同步模式：" + mode)
+	}
+	return catchError(func() {
+		user, err := dao.UserGetByName(middleware.Db, userName)
+		if err != nil {
+			panic(common.NewErr("用户不存在："+userName, err))
+		}
+
+		var report entity.SyncReport
+		if mode == "plan" {
+			report = SyncPlan(user.Id)
+		} else {
+			report = SyncApply(user.Id)
+		}
+
+		for _, action := range report.Actions {
+			middleware.Log.Infof("[%s] %s %s %s", action.Action, action.Path, action.Reason, action.Error)
+		}
+		middleware.Log.Infof("同步%s: {%s} 共%d项", mode, userName, len(report.Actions))
+	})
+}
+
+func newSyncer(userId string) *syncer {
+	user, err := dao.UserGetById(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("用户不存在", err))
+	}
+	lastSync, err := dao.SyncTimeGet(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	paths, err := dao.SyncPathList(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	synced := map[string]bool{}
+	for _, p := range paths {
+		synced[p] = true
+	}
+
+	s := &syncer{
+		user:      user,
+		root:      filepath.Join(common.DataPath, common.ResourceName),
+		lastSync:  lastSync,
+		synced:    synced,
+		books:     map[string]entity.Book{},
+		documents: map[string]entity.Document{},
+		others:    map[string]bool{},
+		dirs:      map[string]fs.FileInfo{},
+		files:     map[string]fs.FileInfo{},
+		actions:   []entity.SyncAction{},
+	}
+	s.loadDb()
+	s.loadDisk()
+	return s
+}
+
+// 加载全部目录和文档的路径
+func (s *syncer) loadDb() {
+	books, err := dao.BookListAll(middleware.Db)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	bookMap := map[string]entity.Book{}
+	for _, book := range books {
+		bookMap[book.Id] = book
+	}
+
+	// 根据上级目录拼接完整路径
+	bookPaths := map[string]string{}
+	for _, book := range books {
+		names := []string{}
+		for current, ok := book, true; ok && len(names) < 100; current, ok = bookMap[current.ParentId] {
+			names = append([]string{current.Name}, names...)
+		}
+		bookPaths[book.Id] = strings.Join(names, "/")
+		if book.UserId == s.user.Id {
+			s.books[bookPaths[book.Id]] = book
+		} else {
+			s.others[bookPaths[book.Id]] = true
+		}
+	}
+
+	documents, err := dao.DocumentListAll(middleware.Db)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	for _, document := range documents {
+		bookPath, ok := bookPaths[document.BookId]
+		if !ok {
+			continue
+		}
+		documentPath := bookPath + "/" + document.Name + entity.MdExt
+		if document.UserId == s.user.Id {
+			s.documents[documentPath] = document
+		} else {
+			s.others[documentPath] = true
+		}
+	}
+}
+
+// 遍历数据目录，忽略图片、缩略图目录及隐藏文件
+func (s *syncer) loadDisk() {
+	err := filepath.WalkDir(s.root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if strings.HasPrefix(d.Name(), ".") || rel == common.PictureName || rel == common.ThumbnailName {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			s.dirs[rel] = info
+		} else if util.FileExt(d.Name()) == entity.MdExt {
+			s.files[rel] = info
+		}
+		return nil
+	})
+	if err != nil {
+		panic(common.NewErr("读取数据目录失败", err))
+	}
+}
+
+// 生成同步计划
+func (s *syncer) plan() {
+	s.planDocuments()
+	s.planBooks()
+}
+
+func (s *syncer) planDocuments() {
+	// 先处理只存在于数据库的文档，已删除的文档名称可被新文件使用
+	names := map[string]bool{}
+	for _, documentPath := range sortedKeys(s.documents) {
+		document := s.documents[documentPath]
+		if _, ok := s.files[documentPath]; ok {
+			names[document.Name] = true
+		} else if s.synced[documentPath] {
+			s.action(entity.SyncDeleteDocument, documentPath, document.Id, "文件已删除")
+		} else {
+			names[document.Name] = true
+			s.action(entity.SyncCreateFile, documentPath, document.Id, "新增文档")
+		}
+	}
+
+	for _, filePath := range sortedKeys(s.files) {
+		if s.others[filePath] {
+			continue
+		}
+		document, ok := s.documents[filePath]
+		if ok {
+			// 两边都存在，内容不同时较新的一方覆盖另一方
+			doc, err := dao.DocumentGetById(middleware.Db, document.Id, s.user.Id)
+			if err != nil {
+				panic(common.NewErr("查询失败", err))
+			}
+			content, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(filePath)))
+			if err != nil {
+				panic(common.NewErr("读取文件失败", err))
+			}
+			if util.EncryptSHA256(content) == util.EncryptSHA256([]byte(doc.Content)) {
+				continue
+			}
+			if s.files[filePath].ModTime().UnixMilli() > doc.UpdateTime {
+				s.action(entity.SyncUpdateDocument, filePath, doc.Id, "文件较新")
+			} else {
+				s.action(entity.SyncUpdateFile, filePath, doc.Id, "文档较新")
+			}
+			continue
+		}
+
+		name := strings.TrimSuffix(path.Base(filePath), path.Ext(filePath))
+		switch {
+		case s.synced[filePath]:
+			s.action(entity.SyncDeleteFile, filePath, "", "文档已删除")
+		case path.Dir(filePath) == ".":
+			s.action(entity.SyncConflict, filePath, "", "根目录下的文件无法同步")
+		case names[name]:
+			s.action(entity.SyncConflict, filePath, "", "已存在同名文档")
+		default:
+			names[name] = true
+			s.action(entity.SyncCreateDocument, filePath, "", "新增文件")
+		}
+	}
+}
+
+// 由深到浅处理目录，保证上级目录判断时已知下级的处理方式
+func (s *syncer) planBooks() {
+	names := map[string]bool{}
+	for _, book := range s.books {
+		names[book.Name] = true
+	}
+
+	paths := []string{}
+	for dirPath := range s.dirs {
+		if _, ok := s.books[dirPath]; !ok && !s.others[dirPath] {
+			paths = append(paths, dirPath)
+		}
+	}
+	for bookPath := range s.books {
+		if _, ok := s.dirs[bookPath]; !ok {
+			paths = append(paths, bookPath)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Count(paths[i], "/") > strings.Count(paths[j], "/") || (strings.Count(paths[i], "/") == strings.Count(paths[j], "/") && paths[i] < paths[j])
+	})
+
+	for _, p := range paths {
+		if book, ok := s.books[p]; ok {
+			// 只存在于数据库，上次同步后两边都存在且下级全部删除时视为文件夹已删除
+			if s.synced[p] && s.onlyDeletedUnder(p, entity.SyncDeleteDocument, entity.SyncDeleteBook) {
+				s.action(entity.SyncDeleteBook, p, book.Id, "文件夹已删除")
+			} else {
+				s.action(entity.SyncCreateDir, p, book.Id, "新增目录")
+			}
+			continue
+		}
+
+		// 只存在于磁盘，上次同步后两边都存在且下级全部删除时视为目录已删除
+		if s.synced[p] && s.onlyDeletedUnder(p, entity.SyncDeleteFile, entity.SyncDeleteDir) && !s.hasOthersUnder(p) {
+			s.action(entity.SyncDeleteDir, p, "", "目录已删除")
+			continue
+		}
+		name := path.Base(p)
+		if names[name] {
+			s.action(entity.SyncConflict, p, "", "已存在同名目录")
+			continue
+		}
+		names[name] = true
+		s.action(entity.SyncCreateBook, p, "", "新增文件夹")
+	}
+}
+
+// 判断路径下的数据库或磁盘条目是否全部计划删除
+func (s *syncer) onlyDeletedUnder(dirPath string, deleteTypes ...entity.SyncActionType) bool {
+	prefix := dirPath + "/"
+	planned := map[string]entity.SyncActionType{}
+	for _, action := range s.actions {
+		planned[action.Path] = action.Action
+	}
+
+	isDelete := func(p string) bool {
+		for _, t := range deleteTypes {
+			if planned[p] == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	if deleteTypes[0] == entity.SyncDeleteDocument {
+		for p := range s.documents {
+			if strings.HasPrefix(p, prefix) && !isDelete(p) {
+				return false
+			}
+		}
+		for p := range s.books {
+			if strings.HasPrefix(p, prefix) && !isDelete(p) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for p := range s.files {
+		if strings.HasPrefix(p, prefix) && !isDelete(p) {
+			return false
+		}
+	}
+	for p := range s.dirs {
+		if strings.HasPrefix(p, prefix) && !isDelete(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// 判断路径下是否有其他用户的数据
+func (s *syncer) hasOthersUnder(dirPath string) bool {
+	for p := range s.others {
+		if strings.HasPrefix(p, dirPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 在一个事务中执行数据库相关的同步
+func (s *syncer) applyDb() {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	order := []entity.SyncActionType{entity.SyncCreateBook, entity.SyncCreateDocument, entity.SyncUpdateDocument, entity.SyncDeleteDocument, entity.SyncDeleteBook}
+	applied := []int{}
+	for _, actionType := range order {
+		for _, i := range s.actionIndexes(actionType) {
+			action := &s.actions[i]
+
+			var err error
+			switch action.Action {
+			case entity.SyncCreateBook:
+				parentId := ""
+				if parentPath := path.Dir(action.Path); parentPath != "." {
+					parent, ok := s.books[parentPath]
+					if !ok {
+						err = errors.New("上级目录不存在")
+						break
+					}
+					parentId = parent.Id
+				}
+				book := entity.Book{Id: util.SnowflakeString(), ParentId: parentId, Name: path.Base(action.Path), CreateTime: now, UserId: s.user.Id}
+				if err = dao.BookAdd(tx, book); err == nil {
+					s.books[action.Path] = book
+					action.Id = book.Id
+				}
+			case entity.SyncCreateDocument:
+				book, ok := s.books[path.Dir(action.Path)]
+				if !ok {
+					err = errors.New("上级目录不存在")
+					break
+				}
+				var content []byte
+				if content, err = s.readFile(action.Path); err != nil {
+					break
+				}
+				document := entity.Document{Id: util.SnowflakeString(), Name: strings.TrimSuffix(path.Base(action.Path), path.Ext(action.Path)), Content: string(content), Type: entity.DocMd, CreateTime: now, UpdateTime: now, BookId: book.Id, UserId: s.user.Id}
+				if err = dao.DocumentAdd(tx, document); err == nil {
+					action.Id = document.Id
+				}
+			case entity.SyncUpdateDocument:
+				var content []byte
+				if content, err = s.readFile(action.Path); err != nil {
+					break
+				}
+				var doc entity.Document
+				if doc, err = dao.DocumentGetById(middleware.Db, action.Id, s.user.Id); err != nil {
+					break
+				}
+				if err = documentRevisionSave(tx, doc, s.user.Id); err != nil {
+					break
+				}
+				err = dao.DocumentUpdateContent(tx, entity.Document{Id: action.Id, Content: string(content), UpdateTime: now, UserId: s.user.Id})
+			case entity.SyncDeleteDocument:
+				// 文件已被删除，文档移入回收站
+				err = dao.DocumentSoftDelete(tx, action.Id, now, s.user.Id)
+			case entity.SyncDeleteBook:
+				_, err = dao.BookSoftDelete(tx, action.Id, now, s.user.Id)
+			}
+
+			if err != nil {
+				action.Error = err.Error()
+			} else {
+				applied = append(applied, i)
+			}
+		}
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("同步失败", err))
+	}
+	for _, i := range applied {
+		s.actions[i].Applied = true
+	}
+}
+
+// 执行磁盘相关的同步
+func (s *syncer) applyFile() {
+	order := []entity.SyncActionType{entity.SyncCreateDir, entity.SyncCreateFile, entity.SyncUpdateFile, entity.SyncDeleteFile, entity.SyncDeleteDir}
+	for _, actionType := range order {
+		for _, i := range s.actionIndexes(actionType) {
+			action := &s.actions[i]
+
+			fullPath := filepath.Join(s.root, filepath.FromSlash(action.Path))
+			var err error
+			switch action.Action {
+			case entity.SyncCreateDir:
+				err = util.CreateDir(fullPath)
+			case entity.SyncCreateFile, entity.SyncUpdateFile:
+				var doc entity.Document
+				if doc, err = dao.DocumentGetById(middleware.Db, action.Id, s.user.Id); err == nil {
+					err = util.CreateFile(filepath.Dir(fullPath), filepath.Base(fullPath), []byte(doc.Content))
+				}
+			case entity.SyncDeleteFile:
+				err = os.Remove(fullPath)
+			case entity.SyncDeleteDir:
+				// 只删除空文件夹，保留其中的其他文件
+				err = os.Remove(fullPath)
+			}
+
+			if err != nil {
+				action.Error = err.Error()
+			} else {
+				action.Applied = true
+			}
+		}
+	}
+}
+
+// 按类型查询同步项下标，创建时由浅到深，删除时由深到浅
+func (s *syncer) actionIndexes(actionType entity.SyncActionType) []int {
+	indexes := []int{}
+	for i, action := range s.actions {
+		if action.Action == actionType {
+			indexes = append(indexes, i)
+		}
+	}
+	desc := actionType == entity.SyncDeleteBook || actionType == entity.SyncDeleteDir
+	sort.SliceStable(indexes, func(i, j int) bool {
+		di, dj := strings.Count(s.actions[indexes[i]].Path, "/"), strings.Count(s.actions[indexes[j]].Path, "/")
+		if desc {
+			return di > dj
+		}
+		return di < dj
+	})
+	return indexes
+}
+
+// 查询两边都存在的目录、文档路径
+func (s *syncer) syncedPaths() []string {
+	paths := []string{}
+	for p := range s.books {
+		if _, ok := s.dirs[p]; ok {
+			paths = append(paths, p)
+		}
+	}
+	for p := range s.documents {
+		if _, ok := s.files[p]; ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (s *syncer) readFile(filePath string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(filePath)))
+	if err == nil && util.StringLength(string(content)) > 10000000 {
+		err = errors.New("文档内容过多，请小于1000万个字符")
+	}
+	return content, err
+}
+
+func (s *syncer) action(actionType entity.SyncActionType, path, id, reason string) {
+	s.actions = append(s.actions, entity.SyncAction{Action: actionType, Path: path, Id: id, Reason: reason})
+}
+
+func (s *syncer) report(dryRun bool, syncTime int64) entity.SyncReport {
+	return entity.SyncReport{
+		DryRun:       dryRun,
+		UserName:     s.user.Name,
+		LastSyncTime: s.lastSync,
+		SyncTime:     syncTime,
+		Actions:      s.actions,
+	}
+}
+
+// 按字典序返回map的key
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 按路径整理同步项，同时校验执行结果
+func syncActions(t *testing.T, report entity.SyncReport) map[string]entity.SyncActionType {
+	t.Helper()
+	actions := map[string]entity.SyncActionType{}
+	for _, action := range report.Actions {
+		if action.Error != "" {
+			t.Errorf("%s %s 执行失败: %s", action.Action, action.Path, action.Error)
+		}
+		if !report.DryRun && action.Action != entity.SyncConflict && !action.Applied {
+			t.Errorf("%s %s 未执行", action.Action, action.Path)
+		}
+		actions[action.Path] = action.Action
+	}
+	return actions
+}
+
+func syncCheck(t *testing.T, step string, actions, want map[string]entity.SyncActionType) {
+	t.Helper()
+	if len(actions) != len(want) {
+		t.Fatalf("%s: 同步项为 %v，期望 %v", step, actions, want)
+	}
+	for p, action := range want {
+		if actions[p] != action {
+			t.Fatalf("%s: 同步项为 %v，期望 %v", step, actions, want)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	dataPath := common.DataPath
+	common.DataPath = t.TempDir()
+	t.Cleanup(func() { common.DataPath = dataPath })
+	root := filepath.Join(common.DataPath, common.ResourceName)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	userId := testUser(t)
+	bookId := testBook(t, userId, "", "同步")
+	testBook(t, userId, bookId, "子目录")
+	documentId := testDocument(t, userId, bookId, "笔记", "a")
+
+	// 只生成计划，不修改磁盘
+	syncCheck(t, "新增目录和文档", syncActions(t, SyncPlan(userId)), map[string]entity.SyncActionType{
+		"同步":       entity.SyncCreateDir,
+		"同步/子目录":   entity.SyncCreateDir,
+		"同步/笔记.md": entity.SyncCreateFile,
+	})
+	if _, err := os.Stat(filepath.Join(root, "同步")); !os.IsNotExist(err) {
+		t.Fatal("同步计划不应修改磁盘")
+	}
+
+	syncCheck(t, "创建文件", syncActions(t, SyncApply(userId)), map[string]entity.SyncActionType{
+		"同步":       entity.SyncCreateDir,
+		"同步/子目录":   entity.SyncCreateDir,
+		"同步/笔记.md": entity.SyncCreateFile,
+	})
+	if content, err := os.ReadFile(filepath.Join(root, "同步", "笔记.md")); err != nil || string(content) != "a" {
+		t.Fatalf("文件内容为 %q", content)
+	}
+	syncCheck(t, "无变化", syncActions(t, SyncPlan(userId)), map[string]entity.SyncActionType{})
+
+	// 修改、新增文件
+	writeFile("同步/笔记.md", "b")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "同步", "笔记.md"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	writeFile("同步/子目录/新文件.md", "c")
+	writeFile("根.md", "d")
+	syncCheck(t, "文件较新", syncActions(t, SyncApply(userId)), map[string]entity.SyncActionType{
+		"同步/笔记.md":      entity.SyncUpdateDocument,
+		"同步/子目录/新文件.md": entity.SyncCreateDocument,
+		"根.md":          entity.SyncConflict,
+	})
+	doc, err := dao.DocumentGetById(middleware.Db, documentId, userId)
+	if err != nil || doc.Content != "b" {
+		t.Fatalf("文档内容为 %q", doc.Content)
+	}
+
+	// 删除文件后同步删除文档
+	if err = os.Remove(filepath.Join(root, "同步", "笔记.md")); err != nil {
+		t.Fatal(err)
+	}
+	syncCheck(t, "文件已删除", syncActions(t, SyncApply(userId)), map[string]entity.SyncActionType{
+		"同步/笔记.md": entity.SyncDeleteDocument,
+		"根.md":     entity.SyncConflict,
+	})
+	if _, err = dao.DocumentGetById(middleware.Db, documentId, userId); err == nil {
+		t.Error("文件删除后文档仍存在")
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 查询标签列表及各标签下的文档数量
+func TagList(userId string) []entity.Tag {
+	tags, err := dao.TagList(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return tags
+}
+
+// 添加标签
+func TagAdd(tag entity.Tag) entity.Tag {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	tag = tagAdd(tx, tag.Name, tag.UserId, false)
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+
+	middleware.Log.Infof("成功添加标签: {%s}", tag.Name)
+	return tag
+}
+
+// 修改标签名称
+func TagRename(tag entity.Tag) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	tag.Name = tagName(tag.Name)
+	_, err := dao.TagGetById(middleware.Db, tag.Id, tag.UserId)
+	if err != nil {
+		panic(common.NewErr("标签不存在", err))
+	}
+
+	tags, err := dao.TagListByName(tx, tag.Name, tag.UserId)
+	if err != nil {
+		panic(common.NewErr("修改失败", err))
+	}
+	if len(tags) > 0 && tags[0].Id != tag.Id {
+		panic(common.NewError("已存在同名标签"))
+	}
+
+	err = dao.TagUpdateName(tx, tag)
+	if err != nil {
+		panic(common.NewErr("修改失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("修改失败", err))
+	}
+
+	middleware.Log.Infof("成功修改标签: {%s}", tag.Name)
+}
+
+// 删除标签，同时移除文档上的该标签
+func TagDelete(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	_, err := dao.TagGetById(middleware.Db, id, userId)
+	if err != nil {
+		panic(common.NewErr("标签不存在", err))
+	}
+
+	err = dao.DocumentTagDeleteByTagId(tx, id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = dao.TagDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	middleware.Log.Infof("成功删除标签: {%s}", id)
+}
+
+// 查询文档的标签列表
+func DocumentTagList(documentId, userId string) []entity.Tag {
+	tags, err := dao.DocumentTagList(middleware.Db, documentId, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return tags
+}
+
+// 为文档添加标签，按名称添加时标签不存在则自动创建
+func DocumentTagAdd(condition entity.DocumentTagCondition, userId string) entity.Tag {
+	// 校验文档归属
+	DocumentGet(condition.DocumentId, userId)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	var tag entity.Tag
+	var err error
+	if condition.TagId != "" {
+		tag, err = dao.TagGetById(middleware.Db, condition.TagId, userId)
+		if err != nil {
+			panic(common.NewErr("标签不存在", err))
+		}
+	} else {
+		tag = tagAdd(tx, condition.Name, userId, true)
+	}
+
+	err = dao.DocumentTagAdd(tx, condition.DocumentId, tag.Id)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+
+	middleware.Log.Infof("成功添加文档标签: {%s} {%s}", condition.DocumentId, tag.Name)
+	return tag
+}
+
+// 移除文档的标签
+func DocumentTagRemove(condition entity.DocumentTagCondition, userId string) {
+	// 校验文档归属
+	DocumentGet(condition.DocumentId, userId)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.DocumentTagDelete(tx, condition.DocumentId, condition.TagId)
+	if err != nil {
+		panic(common.NewErr("移除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("移除失败", err))
+	}
+
+	middleware.Log.Infof("成功移除文档标签: {%s} {%s}", condition.DocumentId, condition.TagId)
+}
+
+// 在事务中添加标签，reuse为true时同名标签已存在则直接返回
+func tagAdd(tx *sqlx.Tx, name, userId string, reuse bool) entity.Tag {
+	name = tagName(name)
+	tags, err := dao.TagListByName(tx, name, userId)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+	if len(tags) > 0 {
+		if reuse {
+			return tags[0]
+		}
+		panic(common.NewError("已存在同名标签"))
+	}
+
+	tag := entity.Tag{
+		Id:         util.SnowflakeString(),
+		Name:       name,
+		CreateTime: time.Now().UnixMilli(),
+		UserId:     userId,
+	}
+	err = dao.TagAdd(tx, tag)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+	return tag
+}
+
+// 校验标签名称
+func tagName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		panic(common.NewError("标签名称不可为空"))
+	}
+	if util.StringLength(name) > 50 {
+		panic(common.NewError("标签名称过长, 请小于50个字符"))
+	}
+	return name
+}
@@ -1,7 +1,6 @@
 package service
 
 import (
-	"fmt"
 	"md/dao"
 	"md/middleware"
 	"md/model/common"
@@ -9,7 +8,7 @@ import (
 	"md/util"
 	"time"
 
-	"github.com/muesli/cache2go"
+	"github.com/jmoiron/sqlx"
 )
 
 const AccessTokenExpire = time.Hour * 24 * 360
@@ -21,16 +20,31 @@ func SignUp(user entity.User) {
 	defer tx.Rollback()
 
 	// 如不允许注册，查询是否没有任何用户
-	if !common.Register {
-		commonResult, err := dao.UserCount(tx)
-		if err != nil {
-			panic(common.NewErr("注册失败", err))
-		}
-		if commonResult.Count > 0 {
-			panic(common.NewError("暂不支持注册"))
-		}
+	userCount, err := dao.UserCount(tx)
+	if err != nil {
+		panic(common.NewErr("注册失败", err))
+	}
+	if !common.Register && userCount.Count > 0 {
+		panic(common.NewError("暂不支持注册"))
 	}
 
+	// 第一个注册的用户为管理员
+	user.Role = common.RoleUser
+	if userCount.Count == 0 {
+		user.Role = common.RoleAdmin
+	}
+	user = userAdd(tx, user)
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("注册失败", err))
+	}
+
+	middleware.Log.Infof("注册用户成功: {%s}", user.Name)
+}
+
+// 校验用户名并保存用户，密码为明文
+func userAdd(tx *sqlx.Tx, user entity.User) entity.User {
 	// 去除用户名的空白
 	user.Name = util.RemoveBlank(user.Name)
 	if user.Name == "" || user.Password == "" {
@@ -53,61 +67,97 @@ func SignUp(user entity.User) {
 
 	// 保存用户信息
 	user.Id = util.SnowflakeString()
-	user.Password = util.EncryptSHA256([]byte(user.Id + user.Password))
+	user.Password = passwordHash(user.Password)
 	user.CreateTime = time.Now().UnixMilli()
-	dao.UserAdd(tx, user)
-
-	err = tx.Commit()
+	err = dao.UserAdd(tx, user)
 	if err != nil {
 		panic(common.NewErr("注册失败", err))
 	}
-
-	go func() {
-		if common.RefreshDb && user.Name == "admin" {
-			middleware.Log.Infof("刷新数据库数据")
-			RefreshDb()
-		}
-	}()
-
-	middleware.Log.Infof("注册用户成功: {%s}", user.Name)
+	return user
 }
 
-// 登录
-func SignIn(user entity.User) common.TokenResult {
+// 登录，ip为客户端ip，用于限制登录失败次数
+func SignIn(user entity.User, ip string) common.TokenResult {
 	// 去除用户名的空白
 	user.Name = util.RemoveBlank(user.Name)
 	if user.Name == "" || user.Password == "" {
 		panic(common.NewError("用户名或密码不可为空"))
 	}
 
-	// 校验登录次数
-	// checkSignInTimes(user.Name)
+	// 校验登录失败次数
+	checkSignInLock(user.Name, ip)
 
 	// 根据用户名查询用户
 	userResult, err := dao.UserGetByName(middleware.Db, user.Name)
 	if err != nil {
+		signInFailed(user.Name, ip)
 		panic(common.NewErr("用户不存在", err))
 	}
 
-	// 匹配密码：sha256(id + password)
-	if util.EncryptSHA256([]byte(userResult.Id+user.Password)) != userResult.Password {
+	// 匹配密码，旧版sha256(id + password)的哈希在登录成功后升级
+	match, rehash := util.VerifyPassword(userResult.Password, user.Password, userResult.Id)
+	if !match {
+		signInFailed(user.Name, ip)
 		panic(common.NewError("密码错误"))
 	}
 
-	// 生成token
+	if userResult.Disabled {
+		panic(common.NewError("用户已被禁用"))
+	}
+
+	if rehash {
+		userRehashPassword(userResult, user.Password)
+	}
+
+	// 已开启两步验证时只返回临时凭证，验证码通过后再生成token
+	if userResult.TotpEnabled {
+		return totpChallenge(userResult)
+	}
+
+	return signInToken(userResult)
+}
+
+// 两步验证登录，使用登录第一步返回的临时凭证和验证码或恢复码
+func SignInTotp(condition entity.TotpCondition, ip string) common.TokenResult {
+	tokenCache, err := middleware.Tokens.Get(common.TotpTokenCache, condition.TotpToken)
+	if err != nil {
+		panic(common.NewError("验证已过期，请重新登录"))
+	}
+
+	// 验证码失败次数与密码失败次数合并计算
+	checkSignInLock(tokenCache.Name, ip)
+
+	user, err := dao.UserGetById(middleware.Db, tokenCache.Id)
+	if err != nil {
+		panic(common.NewErr("用户不存在", err))
+	}
+	if user.Disabled {
+		panic(common.NewError("用户已被禁用"))
+	}
+
+	if !totpVerify(user, condition.Code) {
+		signInFailed(user.Name, ip)
+		panic(common.NewError("验证码错误"))
+	}
+	middleware.Tokens.Delete(common.TotpTokenCache, condition.TotpToken)
+
+	return signInToken(user)
+}
+
+// 生成并保存token，清空登录失败次数
+func signInToken(user entity.User) common.TokenResult {
 	tokenResult := common.TokenResult{}
-	tokenResult.Name = userResult.Name
+	tokenResult.Name = user.Name
 	tokenResult.AccessToken = util.RandomString(64)
 	tokenResult.RefreshToken = util.RandomString(64)
 
 	tokenCache := common.TokenCache{}
-	tokenCache.Id = userResult.Id
+	tokenCache.Id = user.Id
 	tokenCache.TokenResult = tokenResult
 
-	// 缓存token
-	cache2go.Cache(common.AccessTokenCache).Add(tokenResult.AccessToken, AccessTokenExpire, &tokenCache)
-	cache2go.Cache(common.RefreshTokenCache).Add(tokenResult.RefreshToken, RefreshTokenExpire, &tokenCache)
-	// cache2go.Cache(common.SignInTimesCache).Delete(user.Name)
+	// 保存token
+	saveToken(&tokenCache)
+	signInSucceeded(user.Name)
 
 	middleware.Log.Infof("用户登录: {%s}", tokenResult.Name)
 	return tokenResult
@@ -115,25 +165,18 @@ func SignIn(user entity.User) common.TokenResult {
 
 // 退出登录
 func SignOut(tokenResult common.TokenResult) {
-	res, err := cache2go.Cache(common.RefreshTokenCache).Value(tokenResult.RefreshToken)
+	tokenCache, err := middleware.Tokens.Get(common.RefreshTokenCache, tokenResult.RefreshToken)
 	if err == nil {
-		tokenCache := res.Data().(*common.TokenCache)
-		if tokenCache.RefreshToken != "" {
-			cache2go.Cache(common.RefreshTokenCache).Delete(tokenCache.RefreshToken)
-		}
-		if tokenCache.AccessToken != "" {
-			cache2go.Cache(common.AccessTokenCache).Delete(tokenCache.AccessToken)
-		}
+		middleware.Tokens.DeleteSession(tokenCache)
 	}
 }
 
 // 刷新token
 func TokenRefresh(refreshToken string) common.TokenResult {
-	res, err := cache2go.Cache(common.RefreshTokenCache).Value(refreshToken)
+	tokenCache, err := middleware.Tokens.Get(common.RefreshTokenCache, refreshToken)
 	if err != nil {
 		panic(common.NewError("认证信息已过期，请重新登录"))
 	}
-	tokenCache := res.Data().(*common.TokenCache)
 	if tokenCache.RefreshToken == "" {
 		panic(common.NewError("认证信息已过期，请重新登录"))
 	}
@@ -148,39 +191,24 @@ func TokenRefresh(refreshToken string) common.TokenResult {
 	newTokenCache.Id = tokenCache.Id
 	newTokenCache.TokenResult = tokenResult
 
-	// 缓存token
-	cache2go.Cache(common.AccessTokenCache).Add(newTokenCache.AccessToken, AccessTokenExpire, &newTokenCache)
-	cache2go.Cache(common.RefreshTokenCache).Add(newTokenCache.RefreshToken, RefreshTokenExpire, &newTokenCache)
+	// 保存token，旧的AccessToken和RefreshToken随之失效
+	saveToken(&newTokenCache)
+	err = middleware.Tokens.DeleteSession(tokenCache)
+	if err != nil {
+		middleware.Log.Error("删除旧token失败：", err)
+	}
 
 	return tokenResult
 }
 
-// 校验登录次数，如已超出则抛出异常
-func checkSignInTimes(name string) {
-	// 从缓存中获取登录次数信息
-	cache := cache2go.Cache(common.SignInTimesCache)
-	signInTimes, err := cache.Value(name)
-	times := 1
-	expireSecond := int64(0)
-
-	// 如果缓存中不存在该用户的登录次数信息，则添加该用户信息并设置过期时间为1440分钟
+// 保存AccessToken和RefreshToken
+func saveToken(tokenCache *common.TokenCache) {
+	err := middleware.Tokens.Save(common.AccessTokenCache, tokenCache.AccessToken, AccessTokenExpire, tokenCache)
+	if err != nil {
+		panic(common.NewErr("token保存失败", err))
+	}
+	err = middleware.Tokens.Save(common.RefreshTokenCache, tokenCache.RefreshToken, RefreshTokenExpire, tokenCache)
 	if err != nil {
-		cache.Add(name, time.Minute*1440, true)
-	} else {
-		// 计算距离上次登录的时间差
-		expireSecond = 300 - (time.Now().Unix() - signInTimes.CreatedOn().Unix())
-
-		// 如果过期时间小于等于0，说明缓存已过期，手动重置登录次数并设置过期时间为5分钟
-		if expireSecond <= 0 {
-			cache.Add(name, time.Minute*1440, true)
-		} else {
-			// 如果缓存未过期，则更新登录次数
-			times = int(signInTimes.AccessCount()) + 1
-		}
-	}
-
-	// 如果登录次数超过50次，则抛出异常
-	if times > 50 {
-		panic(common.NewError(fmt.Sprintf("登录次数已达上限，请于%v分钟后再试", expireSecond/60+1)))
+		panic(common.NewErr("token保存失败", err))
 	}
 }
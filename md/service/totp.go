@@ -0,0 +1,273 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 两步验证参数
+const (
+	totpIssuer        = "md"            // 验证器应用中显示的发行方
+	totpTokenExpire   = time.Minute * 5 // 登录第一步临时凭证的有效期
+	recoveryCodeCount = 10              // 每次生成的恢复码数量
+	recoveryCodeBytes = 10              // 每个恢复码的随机字节数，80位
+)
+
+// 查询两步验证状态
+func TotpStatusGet(userId string) entity.TotpStatus {
+	user, err := dao.UserGetById(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	count, err := dao.RecoveryCodeCount(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return entity.TotpStatus{Enabled: user.TotpEnabled, RecoveryCodeCount: count}
+}
+
+// 开始设置两步验证，生成待确认的密钥，使用验证码确认后开启
+func TotpSetup(condition entity.TotpCondition, userId, ip string) entity.TotpSetup {
+	user := totpUser(userId, condition.Password, ip)
+	if user.TotpEnabled {
+		panic(common.NewError("已开启两步验证，请先关闭"))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	secret, err := util.TotpSecret()
+	if err != nil {
+		panic(common.NewErr("生成密钥失败", err))
+	}
+	err = dao.UserUpdateTotp(tx, user.Id, secret, false)
+	if err != nil {
+		panic(common.NewErr("设置失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("设置失败", err))
+	}
+
+	return entity.TotpSetup{Secret: secret, Uri: util.TotpUri(totpIssuer, user.Name, secret)}
+}
+
+// 使用验证码确认并开启两步验证，返回恢复码，恢复码只显示这一次
+func TotpEnable(condition entity.TotpCondition, userId string) []string {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	user, err := dao.UserGetById(tx, userId)
+	if err != nil {
+		panic(common.NewErr("开启失败", err))
+	}
+	if user.TotpEnabled {
+		panic(common.NewError("已开启两步验证"))
+	}
+	if user.TotpSecret == "" {
+		panic(common.NewError("请先设置两步验证"))
+	}
+
+	step, ok := util.TotpVerify(user.TotpSecret, util.RemoveBlank(condition.Code), time.Now(), 0)
+	if !ok {
+		panic(common.NewError("验证码错误"))
+	}
+	err = dao.UserUpdateTotp(tx, user.Id, user.TotpSecret, true)
+	if err != nil {
+		panic(common.NewErr("开启失败", err))
+	}
+	_, err = dao.UserUpdateTotpStep(tx, user.Id, step)
+	if err != nil {
+		panic(common.NewErr("开启失败", err))
+	}
+	codes := recoveryCodesReset(tx, user.Id)
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("开启失败", err))
+	}
+
+	middleware.Log.Infof("开启两步验证: {%s}", user.Name)
+	return codes
+}
+
+// 关闭两步验证，需验证密码和验证码或恢复码
+func TotpDisable(condition entity.TotpCondition, userId, ip string) {
+	user := totpCheckEnabled(condition, userId, ip)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	totpClear(tx, user.Id)
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("关闭失败", err))
+	}
+
+	middleware.Log.Infof("关闭两步验证: {%s}", user.Name)
+}
+
+// 重新生成恢复码，之前的恢复码全部失效，需验证密码和验证码或恢复码
+func TotpRecoveryCodes(condition entity.TotpCondition, userId, ip string) []string {
+	user := totpCheckEnabled(condition, userId, ip)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	codes := recoveryCodesReset(tx, user.Id)
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("生成恢复码失败", err))
+	}
+
+	middleware.Log.Infof("重新生成恢复码: {%s}", user.Name)
+	return codes
+}
+
+// 查询用户并校验密码，密码错误与登录失败合并计算失败次数
+func totpUser(userId, password, ip string) entity.User {
+	user, err := dao.UserGetById(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	checkSignInLock(user.Name, ip)
+	if match, _ := util.VerifyPassword(user.Password, password, user.Id); !match {
+		signInFailed(user.Name, ip)
+		panic(common.NewError("密码不正确"))
+	}
+	return user
+}
+
+// 校验已开启两步验证，以及密码和验证码或恢复码
+func totpCheckEnabled(condition entity.TotpCondition, userId, ip string) entity.User {
+	user := totpUser(userId, condition.Password, ip)
+	if !user.TotpEnabled {
+		panic(common.NewError("未开启两步验证"))
+	}
+	if !totpVerify(user, condition.Code) {
+		signInFailed(user.Name, ip)
+		panic(common.NewError("验证码错误"))
+	}
+	return user
+}
+
+// 密码校验通过后生成两步验证的临时凭证
+func totpChallenge(user entity.User) common.TokenResult {
+	tokenResult := common.TokenResult{}
+	tokenResult.Name = user.Name
+	tokenResult.TotpRequired = true
+	tokenResult.TotpToken = randomToken(32)
+
+	tokenCache := common.TokenCache{}
+	tokenCache.Id = user.Id
+	tokenCache.TokenResult = tokenResult
+
+	err := middleware.Tokens.Save(common.TotpTokenCache, tokenResult.TotpToken, totpTokenExpire, &tokenCache)
+	if err != nil {
+		panic(common.NewErr("token保存失败", err))
+	}
+	return tokenResult
+}
+
+// 校验验证码或恢复码，6位数字为验证码，否则为恢复码，通过后验证码的时间步和恢复码均不可再次使用
+func totpVerify(user entity.User, code string) bool {
+	code = recoveryCodeNormalize(code)
+	if code == "" {
+		return false
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	var ok bool
+	var err error
+	if step, match := util.TotpVerify(user.TotpSecret, code, time.Now(), user.TotpLastStep); match {
+		ok, err = dao.UserUpdateTotpStep(tx, user.Id, step)
+	} else {
+		ok, err = recoveryCodeUse(tx, user.Id, code)
+		if ok {
+			middleware.Log.Infof("使用恢复码: {%s}", user.Name)
+		}
+	}
+	if err != nil {
+		panic(common.NewErr("验证失败", err))
+	}
+	if !ok {
+		return false
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("验证失败", err))
+	}
+	return true
+}
+
+// 重新生成恢复码，保存恢复码的哈希，返回恢复码明文
+func recoveryCodesReset(tx *sqlx.Tx, userId string) []string {
+	err := dao.RecoveryCodeDeleteByUser(tx, userId)
+	if err != nil {
+		panic(common.NewErr("生成恢复码失败", err))
+	}
+
+	codes := []string{}
+	hashes := []string{}
+	for i := 0; i < recoveryCodeCount; i++ {
+		token := randomToken(recoveryCodeBytes)
+		codes = append(codes, recoveryCodeFormat(token))
+		hashes = append(hashes, util.EncryptSHA256([]byte(token)))
+	}
+	err = dao.RecoveryCodeAdd(tx, userId, hashes, time.Now().UnixMilli())
+	if err != nil {
+		panic(common.NewErr("生成恢复码失败", err))
+	}
+	return codes
+}
+
+// 恢复码每5个字符以短横线分隔，便于抄写
+func recoveryCodeFormat(code string) string {
+	parts := []string{}
+	for len(code) > 5 {
+		parts = append(parts, code[:5])
+		code = code[5:]
+	}
+	return strings.Join(append(parts, code), "-")
+}
+
+// 去除验证码或恢复码中的空白和短横线并转为小写，输入时可省略分隔符
+func recoveryCodeNormalize(code string) string {
+	return strings.ToLower(strings.ReplaceAll(util.RemoveBlank(code), "-", ""))
+}
+
+// 使用恢复码，code为去除分隔符后的恢复码，同时匹配之前保存的含分隔符的恢复码哈希
+func recoveryCodeUse(tx *sqlx.Tx, userId, code string) (bool, error) {
+	for _, c := range []string{code, recoveryCodeFormat(code)} {
+		ok, err := dao.RecoveryCodeUse(tx, userId, util.EncryptSHA256([]byte(c)))
+		if ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// 清除两步验证的密钥和恢复码
+func totpClear(tx *sqlx.Tx, userId string) {
+	err := dao.UserUpdateTotp(tx, userId, "", false)
+	if err != nil {
+		panic(common.NewErr("关闭失败", err))
+	}
+	err = dao.RecoveryCodeDeleteByUser(tx, userId)
+	if err != nil {
+		panic(common.NewErr("关闭失败", err))
+	}
+}
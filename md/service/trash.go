@@ -0,0 +1,347 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 查询回收站中的文档、目录和图片，按删除时间降序
+func TrashList(userId string) []entity.TrashItem {
+	documents, err := dao.DocumentListDeleted(middleware.Db, 0, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	books, err := dao.BookListDeleted(middleware.Db, 0, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	pictures, err := dao.PictureListDeleted(middleware.Db, 0, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	items := []entity.TrashItem{}
+	for _, document := range documents {
+		items = append(items, entity.TrashItem{Id: document.Id, Type: entity.TrashDocument, Name: document.Name, DeletedTime: document.DeletedTime})
+	}
+	for _, book := range books {
+		items = append(items, entity.TrashItem{Id: book.Id, Type: entity.TrashBook, Name: book.Name, DeletedTime: book.DeletedTime})
+	}
+	for _, picture := range pictures {
+		items = append(items, entity.TrashItem{Id: picture.Id, Type: entity.TrashPicture, Name: picture.Name, DeletedTime: picture.DeletedTime})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedTime > items[j].DeletedTime
+	})
+	return items
+}
+
+// 从回收站恢复
+func TrashRestore(condition entity.TrashCondition, userId string) {
+	switch condition.Type {
+	case entity.TrashDocument:
+		trashDocumentRestore(condition.Id, userId)
+	case entity.TrashBook:
+		trashBookRestore(condition.Id, userId)
+	case entity.TrashPicture:
+		trashPictureRestore(condition.Id, userId)
+	default:
+		panic(common.NewError("不支持的类型"))
+	}
+}
+
+// 从回收站彻底删除
+func TrashPurge(condition entity.TrashCondition, userId string) {
+	switch condition.Type {
+	case entity.TrashDocument:
+		trashDocumentPurge(condition.Id, userId)
+	case entity.TrashBook:
+		trashBookPurge(condition.Id, userId)
+	case entity.TrashPicture:
+		trashPicturePurge(condition.Id, userId)
+	default:
+		panic(common.NewError("不支持的类型"))
+	}
+}
+
+// 定时彻底删除回收站中超过保留天数的条目，days小于等于0时不清理
+func InitTrashPurge(days int) {
+	if days <= 0 {
+		return
+	}
+
+	// 启动时清理一次，之后每小时清理
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		for {
+			TrashPurgeExpired(days)
+			<-ticker.C
+		}
+	}()
+	middleware.Log.Infof("启用回收站定时清理: {%d天}", days)
+}
+
+// 彻底删除全部用户回收站中超过保留天数的条目
+func TrashPurgeExpired(days int) {
+	before := time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixMilli()
+	items := map[entity.TrashCondition]string{}
+
+	documents, err := dao.DocumentListDeleted(middleware.Db, before, "")
+	if err != nil {
+		middleware.Log.Error("清理回收站失败：", err)
+		return
+	}
+	for _, document := range documents {
+		items[entity.TrashCondition{Id: document.Id, Type: entity.TrashDocument}] = document.UserId
+	}
+
+	books, err := dao.BookListDeleted(middleware.Db, before, "")
+	if err != nil {
+		middleware.Log.Error("清理回收站失败：", err)
+		return
+	}
+	for _, book := range books {
+		items[entity.TrashCondition{Id: book.Id, Type: entity.TrashBook}] = book.UserId
+	}
+
+	pictures, err := dao.PictureListDeleted(middleware.Db, before, "")
+	if err != nil {
+		middleware.Log.Error("清理回收站失败：", err)
+		return
+	}
+	for _, picture := range pictures {
+		items[entity.TrashCondition{Id: picture.Id, Type: entity.TrashPicture}] = picture.UserId
+	}
+
+	for condition, userId := range items {
+		err := catchError(func() {
+			TrashPurge(condition, userId)
+		})
+		if err != nil {
+			middleware.Log.Errorf("清理回收站失败: {%s} %s", condition.Id, err)
+		}
+	}
+}
+
+// 回收站目录下的路径
+func trashPath(elem ...string) string {
+	return filepath.Join(append([]string{common.DataPath, common.ResourceName, common.TrashName}, elem...)...)
+}
+
+// 恢复文档，所在目录需未被删除
+func trashDocumentRestore(id, userId string) {
+	doc, err := dao.DocumentGetDeleted(middleware.Db, id, userId)
+	if err != nil {
+		panic(common.NewErr("回收站中不存在该文档", err))
+	}
+
+	var book entity.Book
+	if doc.BookId != "" {
+		book, err = dao.Book(middleware.Db, doc.BookId)
+		if err != nil {
+			panic(common.NewErr("所在目录已删除，请先恢复目录", err))
+		}
+	}
+
+	docs, err := dao.DocumentGetName(middleware.Db, doc.Name, userId)
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+	if len(docs) > 0 {
+		panic(common.NewError("已存在同名文档"))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err = dao.DocumentRestore(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+
+	// 按数据库中的内容重新生成md文件
+	dirPath := bookDirPath(book)
+	go func() {
+		util.CreateFile(dirPath, doc.Name+entity.MdExt, []byte(doc.Content))
+		util.RemoveDir(trashPath(string(entity.TrashDocument), id+entity.MdExt))
+		util.RefreshDir()
+	}()
+
+	middleware.Log.Infof("成功恢复文档: {%s}", doc.Name)
+}
+
+// 彻底删除文档及其历史版本、分享链接和标签
+func trashDocumentPurge(id, userId string) {
+	_, err := dao.DocumentGetDeleted(middleware.Db, id, userId)
+	if err != nil {
+		panic(common.NewErr("回收站中不存在该文档", err))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err = dao.DocumentDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = dao.DocumentRevisionDeleteByDocumentId(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = dao.DocumentShareDeleteByDocumentId(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = dao.DocumentTagDeleteByDocumentId(tx, id)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	util.RemoveDir(trashPath(string(entity.TrashDocument), id+entity.MdExt))
+	middleware.Log.Infof("成功彻底删除文档: {%s}", id)
+}
+
+// 恢复目录，上级目录需未被删除
+func trashBookRestore(id, userId string) {
+	book, err := dao.BookGetDeleted(middleware.Db, id, userId)
+	if err != nil {
+		panic(common.NewErr("回收站中不存在该目录", err))
+	}
+
+	if book.ParentId != "" {
+		_, err = dao.Book(middleware.Db, book.ParentId)
+		if err != nil {
+			panic(common.NewErr("上级目录已删除，请先恢复上级目录", err))
+		}
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	books, err := dao.BookListByName(tx, book.Name, userId)
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+	if len(books) > 0 {
+		panic(common.NewError("已存在同名目录"))
+	}
+
+	err = dao.BookRestore(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+
+	dirPath := bookDirPath(book)
+	go func() {
+		util.MoveFile(trashPath(string(entity.TrashBook), id), dirPath)
+		util.CreateDir(dirPath)
+	}()
+
+	middleware.Log.Infof("成功恢复目录: {%s}", book.Name)
+}
+
+// 彻底删除目录
+func trashBookPurge(id, userId string) {
+	_, err := dao.BookGetDeleted(middleware.Db, id, userId)
+	if err != nil {
+		panic(common.NewErr("回收站中不存在该目录", err))
+	}
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err = dao.BookDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	util.RemoveDir(trashPath(string(entity.TrashBook), id))
+	middleware.Log.Infof("成功彻底删除目录: {%s}", id)
+}
+
+// 恢复图片，图片文件已移入回收站目录时移回
+func trashPictureRestore(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	picture, err := dao.PictureGetDeleted(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("回收站中不存在该图片", err))
+	}
+
+	err = dao.PictureRestore(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("恢复失败", err))
+	}
+
+	pictureFileRestore(picture.Path)
+
+	middleware.Log.Infof("成功恢复图片: {%s}", id)
+}
+
+// 彻底删除图片，回收站中没有其他记录引用同一文件时删除回收站中的文件
+func trashPicturePurge(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	picture, err := dao.PictureGetDeleted(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("回收站中不存在该图片", err))
+	}
+
+	err = dao.PictureDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	countResult, err := dao.PictureCountDeletedByPath(tx, picture.Path)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	if countResult.Count == 0 {
+		util.RemoveDir(trashPath(common.PictureName, picture.Path))
+		util.RemoveDir(trashPath(common.ThumbnailName, picture.Path))
+	}
+
+	middleware.Log.Infof("成功彻底删除图片: {%s}", id)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"md/middleware"
+	"md/model/entity"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	userId := testUser(t)
+	bookId := testBook(t, userId, "", "回收站")
+	documentId := testDocument(t, userId, bookId, "回收站文档", "内容")
+	document := entity.TrashCondition{Id: documentId, Type: entity.TrashDocument}
+	book := entity.TrashCondition{Id: bookId, Type: entity.TrashBook}
+
+	DocumentDelete(documentId, userId)
+	BookDelete(bookId, userId)
+	items := map[entity.TrashCondition]bool{}
+	for _, item := range TrashList(userId) {
+		items[entity.TrashCondition{Id: item.Id, Type: item.Type}] = true
+	}
+	if len(items) != 2 || !items[document] || !items[book] {
+		t.Fatalf("回收站内容不正确: %v", items)
+	}
+	if len(TrashList(testUser(t))) != 0 {
+		t.Fatal("回收站中不应有其他用户的条目")
+	}
+
+	steps := []struct {
+		name    string
+		fn      func()
+		message string
+	}{
+		{"所在目录已删除时恢复文档", func() { TrashRestore(document, userId) }, "所在目录已删除，请先恢复目录"},
+		{"其他用户恢复", func() { TrashRestore(book, testUser(t)) }, "回收站中不存在该目录"},
+		{"恢复目录", func() { TrashRestore(book, userId) }, ""},
+		{"恢复文档", func() { TrashRestore(document, userId) }, ""},
+		{"恢复未删除的文档", func() { TrashRestore(document, userId) }, "回收站中不存在该文档"},
+		{"再次删除文档", func() { DocumentDelete(documentId, userId) }, ""},
+		{"已存在同名文档时恢复", func() {
+			testDocument(t, userId, bookId, "回收站文档", "")
+			TrashRestore(document, userId)
+		}, "已存在同名文档"},
+		{"不支持的类型", func() { TrashPurge(entity.TrashCondition{Id: documentId, Type: "other"}, userId) }, "不支持的类型"},
+		{"彻底删除文档", func() {
+			DocumentShareAdd(entity.DocumentShareCondition{DocumentId: testDocument(t, userId, bookId, "其他文档", "")}, userId)
+			middleware.DbW.MustExec(`update t_document_share set document_id=$1 where user_id=$2`, documentId, userId)
+			TrashPurge(document, userId)
+		}, ""},
+		{"彻底删除已删除的文档", func() { TrashPurge(document, userId) }, "回收站中不存在该文档"},
+	}
+	for _, step := range steps {
+		message := testErrorMessage(step.fn)
+		if message != step.message {
+			t.Fatalf("%s: 错误信息为 %q，期望 %q", step.name, message, step.message)
+		}
+	}
+
+	if len(TrashList(userId)) != 0 {
+		t.Error("彻底删除后回收站应为空")
+	}
+	var count int
+	if err := middleware.Db.Get(&count, `select count(*) from t_document_share where document_id=$1`, documentId); err != nil || count != 0 {
+		t.Errorf("彻底删除文档后仍有 %d 个分享链接", count)
+	}
+}
+
+func TestTrashPurgeExpired(t *testing.T) {
+	userId := testUser(t)
+	bookId := testBook(t, userId, "", "过期")
+	expiredId := testDocument(t, userId, bookId, "过期文档", "")
+	recentId := testDocument(t, userId, bookId, "未过期文档", "")
+	DocumentDelete(expiredId, userId)
+	DocumentDelete(recentId, userId)
+	middleware.DbW.MustExec(`update t_document set deleted_time=$1 where id=$2`, time.Now().AddDate(0, 0, -31).UnixMilli(), expiredId)
+
+	TrashPurgeExpired(30)
+	items := TrashList(userId)
+	if len(items) != 1 || items[0].Id != recentId {
+		t.Errorf("只应清理超过保留天数的条目: %v", items)
+	}
+}
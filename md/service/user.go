@@ -8,7 +8,7 @@ import (
 	"md/util"
 )
 
-// 更新用户密码
+// 更新用户密码，更新后需使用新密码重新登录
 func UserUpdatePassword(userCondition entity.UserCondition) {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
@@ -20,21 +20,72 @@ func UserUpdatePassword(userCondition entity.UserCondition) {
 	}
 
 	// 判断原密码相同
-	if util.EncryptSHA256([]byte(user.Id+userCondition.Password)) != user.Password {
+	if match, _ := util.VerifyPassword(user.Password, userCondition.Password, user.Id); !match {
 		panic(common.NewError("原密码不正确"))
 	}
 
 	// 更新用户
-	user.Password = util.EncryptSHA256([]byte(user.Id + userCondition.NewPassword))
+	user.Password = passwordHash(userCondition.NewPassword)
 	err = dao.UserResetPassword(tx, user)
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
+	// 修改密码后已有的登录token和个人访问令牌全部失效
+	err = dao.ApiTokenDeleteByUser(tx, user.Id)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
 
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
 
+	err = middleware.Tokens.DeleteByUser(user.Id)
+	if err != nil {
+		middleware.Log.Error("删除用户token失败：", err)
+	}
+
 	middleware.Log.Infof("成功更新用户密码: {%s}", user.Name)
 }
+
+// 计算密码哈希
+func passwordHash(password string) string {
+	hash, err := util.HashPassword(password)
+	if err != nil {
+		panic(common.NewErr("密码加密失败", err))
+	}
+	return hash
+}
+
+// 生成不可猜测的令牌
+func randomToken(byteLength int) string {
+	token, err := util.RandomToken(byteLength)
+	if err != nil {
+		panic(common.NewErr("生成令牌失败", err))
+	}
+	return token
+}
+
+// 使用当前算法和参数重新计算密码哈希，失败时不影响登录
+func userRehashPassword(user entity.User, password string) {
+	err := catchError(func() {
+		tx := middleware.DbW.MustBegin()
+		defer tx.Rollback()
+
+		err := dao.UserRehashPassword(tx, user.Id, user.Password, passwordHash(password))
+		if err != nil {
+			panic(err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			panic(err)
+		}
+	})
+	if err != nil {
+		middleware.Log.Errorf("升级密码哈希失败: {%s} %s", user.Name, err)
+		return
+	}
+	middleware.Log.Infof("升级密码哈希: {%s}", user.Name)
+}
@@ -0,0 +1,255 @@
+// 文本差异比较工具类
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+type DiffOp int
+
+const (
+	DiffEqual  DiffOp = iota // 相同行
+	DiffDelete               // 删除行
+	DiffInsert               // 新增行
+)
+
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// 单次查找中间蛇形时前后两个方向各自最多尝试的编辑次数，超出时将该部分整体作为删除和新增
+const diffMaxEdits = 2000
+
+// DiffLines 函数使用线性空间的 Myers 算法比较两组文本行的差异
+// 参数 a 表示原文本行, b 表示新文本行
+// 返回按顺序排列的差异行列表，差异过大时超出部分整体作为删除和新增
+func DiffLines(a, b []string) []DiffLine {
+	result := []DiffLine{}
+	return diffCompare(result, a, b)
+}
+
+// 去掉相同的开头和结尾后查找中间蛇形，将差异追加到 result
+func diffCompare(result []DiffLine, a, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result = diffAppend(result, DiffEqual, a[:prefix])
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch {
+	case len(midA) == 0:
+		result = diffAppend(result, DiffInsert, midB)
+	case len(midB) == 0:
+		result = diffAppend(result, DiffDelete, midA)
+	default:
+		if x, y, ok := diffMiddleSnake(midA, midB); ok {
+			result = diffCompare(result, midA[:x], midB[:y])
+			result = diffCompare(result, midA[x:], midB[y:])
+		} else {
+			result = diffAppend(result, DiffDelete, midA)
+			result = diffAppend(result, DiffInsert, midB)
+		}
+	}
+	return diffAppend(result, DiffEqual, a[len(a)-suffix:])
+}
+
+// 从两端同时搜索最短编辑路径，返回两个方向相遇的位置，用于将比较拆分为前后两部分
+// 只使用 O(len(a)+len(b)) 的内存，编辑次数超过 diffMaxEdits 时返回 false
+func diffMiddleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	if maxD > diffMaxEdits {
+		maxD = diffMaxEdits
+	}
+	offset := maxD + 1
+	// v1[offset+k]、v2[offset+k] 分别为正向、反向在对角线 k 上到达的最远 x，-1 表示未到达
+	v1 := make([]int, 2*offset+1)
+	v2 := make([]int, 2*offset+1)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[offset+1] = 0
+	v2[offset+1] = 0
+
+	delta := n - m
+	// 总行数为奇数时由正向检测相遇，否则由反向检测
+	front := delta%2 != 0
+	// 超出边界的对角线不再搜索
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		// 正向前进一步
+		for k1 := -d + k1start; k1 <= d-k1end; k1 += 2 {
+			var x1 int
+			if k1 == -d || (k1 != d && v1[offset+k1-1] < v1[offset+k1+1]) {
+				x1 = v1[offset+k1+1]
+			} else {
+				x1 = v1[offset+k1-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[offset+k1] = x1
+			if x1 > n {
+				k1end += 2
+			} else if y1 > m {
+				k1start += 2
+			} else if front {
+				k2 := offset + delta - k1
+				if k2 >= 0 && k2 < len(v2) && v2[k2] != -1 && x1 >= n-v2[k2] {
+					return x1, y1, true
+				}
+			}
+		}
+
+		// 反向前进一步，x2、y2 为距离末尾的行数
+		for k2 := -d + k2start; k2 <= d-k2end; k2 += 2 {
+			var x2 int
+			if k2 == -d || (k2 != d && v2[offset+k2-1] < v2[offset+k2+1]) {
+				x2 = v2[offset+k2+1]
+			} else {
+				x2 = v2[offset+k2-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[offset+k2] = x2
+			if x2 > n {
+				k2end += 2
+			} else if y2 > m {
+				k2start += 2
+			} else if !front {
+				k1 := offset + delta - k2
+				if k1 >= 0 && k1 < len(v1) && v1[k1] != -1 {
+					x1 := v1[k1]
+					y1 := offset + x1 - k1
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// 将多行追加为同一种差异
+func diffAppend(result []DiffLine, op DiffOp, lines []string) []DiffLine {
+	for _, line := range lines {
+		result = append(result, DiffLine{Op: op, Text: line})
+	}
+	return result
+}
+
+// UnifiedDiff 函数生成两段文本按行比较的 unified 格式差异
+// 参数 fromName、toName 表示差异头部显示的新旧名称
+// 参数 from、to 表示新旧文本
+// 参数 context 表示变更行前后保留的上下文行数
+// 返回 unified 格式的差异文本，无差异时返回空字符串
+func UnifiedDiff(fromName, toName, from, to string, context int) string {
+	return FormatUnifiedDiff(fromName, toName, DiffLines(SplitLines(from), SplitLines(to)), context)
+}
+
+// FormatUnifiedDiff 函数将 DiffLines 的结果格式化为 unified 格式的差异文本
+// 参数含义与 UnifiedDiff 相同, lines 表示按顺序排列的差异行列表
+func FormatUnifiedDiff(fromName, toName string, lines []DiffLine, context int) string {
+	// 计算每一行之前已经过的原文本、新文本行数
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, line := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if line.Op != DiffInsert {
+			aPos[i+1]++
+		}
+		if line.Op != DiffDelete {
+			bPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	i := 0
+	for i < len(lines) {
+		// 查找下一处变更
+		for i < len(lines) && lines[i].Op == DiffEqual {
+			i++
+		}
+		if i >= len(lines) {
+			break
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// 间隔不超过2倍上下文的变更合并为一个片段
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != DiffEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == DiffEqual {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+		stop := end + context
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		if sb.Len() == 0 {
+			sb.WriteString("--- " + fromName + "\n")
+			sb.WriteString("+++ " + toName + "\n")
+		}
+		sb.WriteString("@@ -" + hunkRange(aPos[start], aPos[stop]-aPos[start]) + " +" + hunkRange(bPos[start], bPos[stop]-bPos[start]) + " @@\n")
+		for _, line := range lines[start:stop] {
+			switch line.Op {
+			case DiffEqual:
+				sb.WriteString(" ")
+			case DiffDelete:
+				sb.WriteString("-")
+			case DiffInsert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(line.Text)
+			sb.WriteString("\n")
+		}
+		i = stop
+	}
+
+	return sb.String()
+}
+
+// SplitLines 函数将文本按行拆分，忽略末尾换行符
+func SplitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// 生成片段头部的行范围，起始行从1开始，行数为0时起始行为前一行
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return strconv.Itoa(start) + ",0"
+	}
+	return strconv.Itoa(start+1) + "," + strconv.Itoa(count)
+}
@@ -0,0 +1,206 @@
+package util
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 将差异行还原为原文本和新文本，用于校验差异结果
+func diffApply(lines []DiffLine) ([]string, []string) {
+	a, b := []string{}, []string{}
+	for _, line := range lines {
+		if line.Op != DiffInsert {
+			a = append(a, line.Text)
+		}
+		if line.Op != DiffDelete {
+			b = append(b, line.Text)
+		}
+	}
+	return a, b
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		inserts int
+		deletes int
+	}{
+		{"空文本", "", "", 0, 0},
+		{"相同", "a\nb\nc", "a\nb\nc", 0, 0},
+		{"全部新增", "", "a\nb", 2, 0},
+		{"全部删除", "a\nb", "", 0, 2},
+		{"修改一行", "a\nb\nc", "a\nx\nc", 1, 1},
+		{"开头插入", "b\nc", "a\nb\nc", 1, 0},
+		{"末尾删除", "a\nb\nc", "a\nb", 0, 1},
+		{"经典示例", "a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc", 2, 3},
+		{"以减号开头的行", "-- x\nb", "++ x\nb", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := SplitLines(tt.a), SplitLines(tt.b)
+			lines := DiffLines(a, b)
+
+			gotA, gotB := diffApply(lines)
+			if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+				t.Fatalf("差异无法还原原文本: %v", lines)
+			}
+
+			inserts, deletes := 0, 0
+			for _, line := range lines {
+				switch line.Op {
+				case DiffInsert:
+					inserts++
+				case DiffDelete:
+					deletes++
+				}
+			}
+			if inserts != tt.inserts || deletes != tt.deletes {
+				t.Errorf("新增 %d 删除 %d，期望新增 %d 删除 %d", inserts, deletes, tt.inserts, tt.deletes)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		context  int
+		want     string
+	}{
+		{"无差异", "a\nb", "a\nb", 3, ""},
+		{
+			"修改一行",
+			"a\nb\nc", "a\nx\nc", 3,
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			"新增到空文本",
+			"", "a\nb", 3,
+			"--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"全部删除",
+			"a", "", 3,
+			"--- old\n+++ new\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			"上下文分隔为两个片段",
+			"1\n2\n3\n4\n5\n6\n7\n8", "x\n2\n3\n4\n5\n6\n7\ny", 1,
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+y\n",
+		},
+		{
+			"以减号开头的行",
+			"-- x", "++ x", 0,
+			"--- old\n+++ new\n@@ -1,1 +1,1 @@\n--- x\n+++ x\n",
+		},
+		{
+			"忽略CRLF",
+			"a\r\nb\r\n", "a\nb\n", 3, "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("old", "new", tt.from, tt.to, tt.context)
+			if got != tt.want {
+				t.Errorf("差异结果:\n%s\n期望:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// 使用最长公共子序列计算最少编辑次数，用于校验差异结果是否最短
+func diffMinEdits(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = strconv.Itoa(rnd.Intn(4))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		lines := DiffLines(a, b)
+		gotA, gotB := diffApply(lines)
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Fatalf("差异无法还原原文本: %v %v", a, b)
+		}
+		edits := 0
+		for _, line := range lines {
+			if line.Op != DiffEqual {
+				edits++
+			}
+		}
+		if want := diffMinEdits(a, b); edits != want {
+			t.Fatalf("%v -> %v 编辑 %d 次，最少为 %d 次", a, b, edits, want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  []string
+		edits int
+	}{
+		{"完全不同", diffTestLines("a", 20000, 1), diffTestLines("b", 20000, 1), 40000},
+		{"少量修改", diffTestLines("a", 50000, 1), diffTestLines("a", 50000, 1000), 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			lines := DiffLines(tt.a, tt.b)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("比较耗时 %s", elapsed)
+			}
+			gotA, gotB := diffApply(lines)
+			if len(gotA) != len(tt.a) || len(gotB) != len(tt.b) || strings.Join(gotB, "\n") != strings.Join(tt.b, "\n") {
+				t.Fatal("差异无法还原原文本")
+			}
+			edits := 0
+			for _, line := range lines {
+				if line.Op != DiffEqual {
+					edits++
+				}
+			}
+			if edits != tt.edits {
+				t.Errorf("编辑 %d 次，期望 %d 次", edits, tt.edits)
+			}
+		})
+	}
+}
+
+// 生成测试文本行，每隔 every 行的内容与前缀无关，用于构造少量修改
+func diffTestLines(prefix string, count, every int) []string {
+	lines := make([]string, count)
+	for i := range lines {
+		if every > 1 && i%every == 0 {
+			lines[i] = "changed" + strconv.Itoa(i)
+		} else {
+			lines[i] = prefix + strconv.Itoa(i)
+		}
+	}
+	return lines
+}
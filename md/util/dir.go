@@ -1,6 +1,7 @@
 package util
 
 import (
+	"md/middleware"
 	"os"
 	"path/filepath"
 )
@@ -11,7 +12,7 @@ import (
 func CreateDir(dirPath ...string) error {
 	err := os.MkdirAll(filepath.Join(dirPath...), 0755)
 	if err != nil {
-		log.Errorf("创建目录失败: {%s}", err)
+		middleware.Log.Errorf("创建目录失败: {%s}", err)
 		return err
 	}
 
@@ -30,7 +31,7 @@ func RenameDir(oldPath string, newPath string) error {
 
 	err := os.Rename(oldPath, newPath)
 	if err != nil {
-		log.Errorf("修改目录名失败: {%s}", err)
+		middleware.Log.Errorf("修改目录名失败: {%s}", err)
 		return err
 	}
 
@@ -42,7 +43,7 @@ func RenameDir(oldPath string, newPath string) error {
 func RemoveDir(dirPath ...string) {
 	err := os.RemoveAll(filepath.Join(dirPath...))
 	if err != nil {
-		log.Errorf("删除目录失败: {%s}", err)
+		middleware.Log.Errorf("删除目录失败: {%s}", err)
 	}
 }
 
@@ -0,0 +1,173 @@
+// 订阅源、站点地图生成工具类
+package util
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// 订阅源
+type Feed struct {
+	Title       string
+	Link        string // 站点地址
+	FeedLink    string // 订阅源自身地址
+	Description string
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+// 订阅源条目
+type FeedItem struct {
+	Id        string
+	Title     string
+	Link      string
+	Summary   string
+	Author    string
+	Category  string
+	Published time.Time
+	Updated   time.Time
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	Description string  `xml:"description"`
+	Author      string  `xml:"author,omitempty"`
+	Category    string  `xml:"category,omitempty"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// FeedRSS 函数生成 RSS 2.0 格式的订阅源
+func FeedRSS(feed Feed) ([]byte, error) {
+	channel := rssChannel{
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Description: feed.Description,
+		Items:       []rssItem{},
+	}
+	if !feed.Updated.IsZero() {
+		channel.LastBuildDate = feed.Updated.Format(time.RFC1123Z)
+	}
+	for _, item := range feed.Items {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Guid:        rssGuid{IsPermaLink: item.Id == item.Link, Value: item.Id},
+			Description: item.Summary,
+			Author:      item.Author,
+			Category:    item.Category,
+			PubDate:     item.Published.Format(time.RFC1123Z),
+		})
+	}
+	return marshalXml(rss{Version: "2.0", Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Id        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Summary   string      `xml:"summary,omitempty"`
+	Author    *atomAuthor `xml:"author"`
+	Category  *atomTerm   `xml:"category"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomTerm struct {
+	Term string `xml:"term,attr"`
+}
+
+// FeedAtom 函数生成 Atom 格式的订阅源
+func FeedAtom(feed Feed) ([]byte, error) {
+	atom := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Id:      feed.FeedLink,
+		Title:   feed.Title,
+		Updated: feed.Updated.Format(time.RFC3339),
+		Links:   []atomLink{{Href: feed.Link}, {Href: feed.FeedLink, Rel: "self"}},
+		Entries: []atomEntry{},
+	}
+	for _, item := range feed.Items {
+		entry := atomEntry{
+			Id:        item.Id,
+			Title:     item.Title,
+			Link:      atomLink{Href: item.Link},
+			Published: item.Published.Format(time.RFC3339),
+			Updated:   item.Updated.Format(time.RFC3339),
+			Summary:   item.Summary,
+		}
+		if item.Author != "" {
+			entry.Author = &atomAuthor{Name: item.Author}
+		}
+		if item.Category != "" {
+			entry.Category = &atomTerm{Term: item.Category}
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+	return marshalXml(atom)
+}
+
+// 站点地图中的地址
+type SitemapUrl struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Urls    []SitemapUrl `xml:"url"`
+}
+
+// Sitemap 函数生成 sitemap.xml 内容
+func Sitemap(urls []SitemapUrl) ([]byte, error) {
+	return marshalXml(sitemap{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", Urls: urls})
+}
+
+// 生成带xml声明的缩进xml
+func marshalXml(v interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -5,6 +5,7 @@ import (
 	"md/middleware"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // CreateFile 函数用于创建文件并写入内容
@@ -19,14 +20,14 @@ func CreateFile(dirPath string, fileName string, content []byte) error {
 
 	saveMdFile, err := os.Create(filepath.Join(dirPath, fileName))
 	if err != nil {
-		log.Errorf("创建文件失败: {%s}", err)
+		middleware.Log.Errorf("创建文件失败: {%s}", err)
 		return err
 	}
 	defer saveMdFile.Close()
 
 	_, err = saveMdFile.Write(content)
 	if err != nil {
-		log.Errorf("写入文件错误: {%s}", err)
+		middleware.Log.Errorf("写入文件错误: {%s}", err)
 		return err
 	}
 
@@ -48,7 +49,7 @@ func RenameFile(dirPath string, oldFileName string, newFileName string) error {
 	newFile := filepath.Join(dirPath, newFileName)
 	err := os.Rename(oldFile, newFile)
 	if err != nil {
-		log.Errorf("重命名文件失败: {%s}", err)
+		middleware.Log.Errorf("重命名文件失败: {%s}", err)
 		return err
 	}
 
@@ -85,3 +86,40 @@ func ReadFileContent(filePath string) string {
 
 	return string(content)
 }
+
+// SafeFileName 函数将名称中不能用于文件名的字符替换为下划线
+// 参数 name 表示原名称
+// 返回可作为文件名或目录名使用的名称
+func SafeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// MoveFile 函数用于移动文件或目录，原路径不存在时不执行移动操作
+// 参数 oldPath 表示原路径
+// 参数 newPath 表示新路径，上级目录不存在时自动创建
+// 返回可能的错误
+func MoveFile(oldPath string, newPath string) error {
+	if !IsDirExist(oldPath) {
+		return nil
+	}
+	if err := CreateDir(filepath.Dir(newPath)); err != nil {
+		return err
+	}
+
+	err := os.Rename(oldPath, newPath)
+	if err != nil {
+		middleware.Log.Errorf("移动文件失败: {%s}", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// 关键字高亮工具类
+package util
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// HighlightSnippet 函数截取文本中首个关键字附近的摘要并高亮关键字
+// 参数 text 表示原文本, 连续空白会被合并为一个空格
+// 参数 keywords 表示关键字列表, 忽略大小写
+// 参数 length 表示摘要的最大字符数
+// 返回html转义后的摘要, 关键字使用<mark>标签包裹
+func HighlightSnippet(text string, keywords []string, length int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	lower := lowerRunes(runes)
+
+	// 关键字前保留约四分之一的长度作为上文
+	start := 0
+	for _, keyword := range keywords {
+		if pos := runeIndex(lower, lowerRunes([]rune(keyword))); pos >= 0 {
+			if pos > length/4 {
+				start = pos - length/4
+			}
+			break
+		}
+	}
+	end := start + length
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := Highlight(string(runes[start:end]), keywords)
+	if start > 0 {
+		snippet = "..." + snippet
+	}
+	if end < len(runes) {
+		snippet += "..."
+	}
+	return snippet
+}
+
+// Highlight 函数高亮文本中的全部关键字
+// 参数 text 表示原文本
+// 参数 keywords 表示关键字列表, 忽略大小写
+// 返回html转义后的文本, 关键字使用<mark>标签包裹
+func Highlight(text string, keywords []string) string {
+	runes := []rune(text)
+	lower := lowerRunes(runes)
+
+	// 标记需要高亮的字符
+	marks := make([]bool, len(runes))
+	for _, keyword := range keywords {
+		target := lowerRunes([]rune(keyword))
+		if len(target) == 0 {
+			continue
+		}
+		for i := 0; i+len(target) <= len(lower); i++ {
+			if runesEqual(lower[i:i+len(target)], target) {
+				for j := i; j < i+len(target); j++ {
+					marks[j] = true
+				}
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marks[j] == marks[i] {
+			j++
+		}
+		chunk := html.EscapeString(string(runes[i:j]))
+		if marks[i] {
+			sb.WriteString("<mark>" + chunk + "</mark>")
+		} else {
+			sb.WriteString(chunk)
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+// 逐字符转小写，保持字符数量不变
+func lowerRunes(runes []rune) []rune {
+	result := make([]rune, len(runes))
+	for i, r := range runes {
+		result[i] = unicode.ToLower(r)
+	}
+	return result
+}
+
+// 查找子串首次出现的位置，不存在返回-1
+func runeIndex(runes, target []rune) int {
+	if len(target) == 0 {
+		return -1
+	}
+	for i := 0; i+len(target) <= len(runes); i++ {
+		if runesEqual(runes[i:i+len(target)], target) {
+			return i
+		}
+	}
+	return -1
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
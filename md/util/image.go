@@ -0,0 +1,80 @@
+// 图片处理工具类
+package util
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 允许解码的最大像素数，防止声明超大尺寸的小文件解码时占用大量内存
+const maxImagePixels = 40 * 1000 * 1000
+
+// 图片像素数超过限制
+var ErrImageTooLarge = errors.New("图片尺寸过大")
+
+// GenerateThumbnail 函数生成图片缩略图，按原比例缩放至不超过指定宽高，不会放大
+// 参数 data 表示原图片数据, 支持 PNG/APNG、JPEG、GIF、BMP、WebP
+// 参数 maxWidth、maxHeight 表示缩略图的最大宽高
+// 返回缩略图数据, JPEG、PNG、GIF、BMP 保持原格式编码, WebP 没有编码器, 编码为 PNG;
+// 像素数超过限制时返回 ErrImageTooLarge, 无法解码时返回error
+func GenerateThumbnail(data []byte, maxWidth, maxHeight int) ([]byte, error) {
+	// 解码前先读取宽高校验像素数
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// 计算缩放后的宽高
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+	case "gif":
+		err = gif.Encode(&buf, dst, nil)
+	case "bmp":
+		err = bmp.Encode(&buf, dst)
+	case "png", "webp":
+		err = png.Encode(&buf, dst)
+	default:
+		return nil, errors.New("不支持的图片格式：" + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
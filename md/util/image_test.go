@@ -0,0 +1,109 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+)
+
+// 生成指定宽高的测试图片并按格式编码
+func imageTestData(t *testing.T, format string, width, height int) []byte {
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, src)
+	case "jpeg":
+		err = jpeg.Encode(&buf, src, nil)
+	case "gif":
+		err = gif.Encode(&buf, src, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 只有文件头的PNG，声明指定宽高，用于校验解码前的像素数限制
+func imageTestPngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12], ihdr[13] = 8, 2 // 8位RGB
+	data := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	webp, err := os.ReadFile("testdata/blue-purple-pink.lossy.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		data          []byte
+		format        string // 缩略图的编码格式
+		width, height int
+	}{
+		{"PNG缩小", imageTestData(t, "png", 400, 200), "png", 100, 50},
+		{"JPEG缩小", imageTestData(t, "jpeg", 200, 400), "jpeg", 50, 100},
+		{"GIF不放大", imageTestData(t, "gif", 40, 30), "gif", 40, 30},
+		{"WebP编码为PNG", webp, "png", 100, 66},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbnail, err := GenerateThumbnail(tt.data, 100, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config, format, err := image.DecodeConfig(bytes.NewReader(thumbnail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.format || config.Width != tt.width || config.Height != tt.height {
+				t.Errorf("缩略图为 %s %dx%d，期望 %s %dx%d", format, config.Width, config.Height, tt.format, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestGenerateThumbnailInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		tooLarge bool
+	}{
+		{"超过4000万像素", imageTestPngHeader(8000, 6000), true},
+		{"单边超长", imageTestPngHeader(100000, 1000), true},
+		{"无法识别", []byte("not an image"), false},
+		{"内容不完整", imageTestPngHeader(100, 100), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateThumbnail(tt.data, 100, 100)
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			if errors.Is(err, ErrImageTooLarge) != tt.tooLarge {
+				t.Errorf("错误为 %v", err)
+			}
+		})
+	}
+}
@@ -1,5 +0,0 @@
-package util
-
-import "md/middleware"
-
-var log = middleware.Log
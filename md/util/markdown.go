@@ -0,0 +1,146 @@
+// markdown渲染工具类
+package util
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// 目录中的标题
+type MarkdownHeading struct {
+	Level int    // 标题级别，1~6
+	Id    string // 标题锚点id
+	Text  string // 标题文本
+}
+
+var (
+	// 任务列表前缀，如 [ ] 或 [x]
+	taskPrefixRegexp = regexp.MustCompile(`^\[([ xX])\]\s+`)
+	// 相对路径的图片，如 ../../picture/a.png
+	relativePictureRegexp = regexp.MustCompile(`^(\.\./)+`)
+	// 渲染结果的安全策略
+	markdownPolicy = newMarkdownPolicy()
+)
+
+// RenderMarkdown 函数将markdown渲染为安全的html
+// 支持表格、任务列表、围栏代码块（language-xxx类名，用于语法高亮）、标题锚点
+// 参数 source 表示markdown文本
+// 参数 pictureName 表示图片目录名
+// 参数 pictureBase 表示相对路径图片替换后的前缀，如 /picture/，为空时不替换
+// 返回过滤后的html和标题目录
+func RenderMarkdown(source, pictureName, pictureBase string) (string, []MarkdownHeading) {
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock | parser.Footnotes
+	doc := markdown.Parse([]byte(strings.ReplaceAll(source, "\r\n", "\n")), parser.NewWithExtensions(extensions))
+
+	headings := []MarkdownHeading{}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Heading:
+			headings = append(headings, MarkdownHeading{Level: n.Level, Id: n.HeadingID, Text: markdownNodeText(n)})
+		case *ast.ListItem:
+			markdownTaskItem(n)
+		case *ast.Image:
+			if pictureBase != "" {
+				n.Destination = markdownPicture(n.Destination, pictureName, pictureBase)
+			}
+		}
+		return ast.GoToNext
+	})
+
+	renderer := html.NewRenderer(html.RendererOptions{Flags: html.LazyLoadImages})
+	return string(markdownPolicy.SanitizeBytes(markdown.Render(doc, renderer))), headings
+}
+
+// MarkdownSummary 函数提取markdown第一个段落的纯文本作为摘要
+// 参数 maxLength 表示摘要最大字符数，超出时截断并添加省略号
+func MarkdownSummary(source string, maxLength int) string {
+	doc := markdown.Parse([]byte(strings.ReplaceAll(source, "\r\n", "\n")), parser.NewWithExtensions(parser.CommonExtensions|parser.NoEmptyLineBeforeBlock))
+
+	summary := ""
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if paragraph, ok := node.(*ast.Paragraph); ok && entering {
+			summary = markdownNodeText(paragraph)
+			if summary != "" {
+				return ast.Terminate
+			}
+		}
+		return ast.GoToNext
+	})
+
+	summary = strings.Join(strings.Fields(summary), " ")
+	runes := []rune(summary)
+	if len(runes) > maxLength {
+		return string(runes[:maxLength]) + "…"
+	}
+	return summary
+}
+
+// 列表项以 [ ] 或 [x] 开头时替换为复选框
+func markdownTaskItem(item *ast.ListItem) {
+	paragraph, ok := ast.GetFirstChild(item).(*ast.Paragraph)
+	if !ok {
+		return
+	}
+	text, ok := ast.GetFirstChild(paragraph).(*ast.Text)
+	if !ok {
+		return
+	}
+	match := taskPrefixRegexp.FindSubmatch(text.Literal)
+	if match == nil {
+		return
+	}
+
+	checkbox := `<input type="checkbox" disabled> `
+	if match[1][0] != ' ' {
+		checkbox = `<input type="checkbox" checked disabled> `
+	}
+	text.Literal = text.Literal[len(match[0]):]
+	span := &ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(checkbox)}}
+	span.SetParent(paragraph)
+	paragraph.SetChildren(append([]ast.Node{span}, paragraph.GetChildren()...))
+}
+
+// 将 ../picture/xxx 形式的图片地址替换为指定前缀
+func markdownPicture(destination []byte, pictureName, pictureBase string) []byte {
+	trimmed := relativePictureRegexp.ReplaceAll(destination, nil)
+	if !bytes.HasPrefix(trimmed, []byte(pictureName+"/")) {
+		return destination
+	}
+	return append([]byte(pictureBase), trimmed[len(pictureName)+1:]...)
+}
+
+// 拼接节点下的全部文本
+func markdownNodeText(node ast.Node) string {
+	var sb strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); entering && leaf != nil {
+			switch n.(type) {
+			case *ast.Text, *ast.Code:
+				sb.Write(leaf.Literal)
+			}
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(sb.String())
+}
+
+// 在通用安全策略的基础上允许标题锚点、代码语言类名和任务列表复选框
+func newMarkdownPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("id").Matching(regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+	policy.AllowAttrs("loading").Matching(regexp.MustCompile(`^lazy$`)).OnElements("img")
+	return policy
+}
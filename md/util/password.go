@@ -0,0 +1,75 @@
+// 密码哈希工具类
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id参数
+const (
+	argon2Memory  = 64 * 1024 // 内存，单位KiB
+	argon2Time    = 3         // 迭代次数
+	argon2Threads = 2         // 并行度
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// argon2id哈希的算法前缀
+const argon2Prefix = "$argon2id$"
+
+// HashPassword 使用argon2id计算密码哈希，结果为带算法前缀和参数的编码字符串：
+// $argon2id$v=19$m=65536,t=3,p=2$盐$哈希
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword 校验密码，返回是否匹配，以及匹配时是否需要使用当前参数重新哈希
+// 没有算法前缀的为旧版哈希sha256(legacySalt + password)，匹配时总是需要重新哈希
+func VerifyPassword(encoded, password, legacySalt string) (bool, bool) {
+	if !strings.HasPrefix(encoded, argon2Prefix) {
+		legacy := EncryptSHA256([]byte(legacySalt + password))
+		match := subtle.ConstantTimeCompare([]byte(legacy), []byte(encoded)) == 1
+		return match, match
+	}
+
+	var version int
+	var memory, time uint32
+	var threads uint8
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(actual, key) != 1 {
+		return false, false
+	}
+	rehash := memory != argon2Memory || time != argon2Time || threads != argon2Threads || len(salt) != argon2SaltLen || len(key) != argon2KeyLen
+	return true, rehash
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashPassword(t *testing.T) {
+	encoded, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Fatalf("哈希格式不正确: %s", encoded)
+	}
+
+	// 相同密码每次使用不同的盐
+	other, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == encoded {
+		t.Error("相同密码的哈希不应相同")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	encoded, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(encoded, "$")
+	legacy := EncryptSHA256([]byte("salt" + "secret"))
+
+	// 修改哈希的第一个字符，最后一个字符含未使用的填充位，修改后可能解码出相同的哈希
+	hashStart := strings.LastIndex(encoded, "$") + 1
+	replacement := "A"
+	if encoded[hashStart] == 'A' {
+		replacement = "B"
+	}
+	tampered := encoded[:hashStart] + replacement + encoded[hashStart+1:]
+
+	tests := []struct {
+		name       string
+		encoded    string
+		password   string
+		legacySalt string
+		match      bool
+		rehash     bool
+	}{
+		{"argon2id正确", encoded, "secret", "", true, false},
+		{"argon2id错误", encoded, "wrong", "", false, false},
+		{"argon2id空密码", encoded, "", "", false, false},
+		{"旧版正确需升级", legacy, "secret", "salt", true, true},
+		{"旧版错误", legacy, "wrong", "salt", false, false},
+		{"旧版盐不一致", legacy, "secret", "other", false, false},
+		{"篡改哈希", tampered, "secret", "", false, false},
+		{"篡改盐", strings.Join([]string{"", parts[1], parts[2], parts[3], "AAAAAAAAAAAAAAAAAAAAAA", parts[5]}, "$"), "secret", "", false, false},
+		{"字段数量不正确", strings.Join(parts[:5], "$"), "secret", "", false, false},
+		{"版本不支持", strings.Replace(encoded, "v=19", "v=16", 1), "secret", "", false, false},
+		{"参数无法解析", strings.Replace(encoded, "m=65536", "m=x", 1), "secret", "", false, false},
+		{"哈希为空", strings.Join(parts[:5], "$") + "$", "secret", "", false, false},
+		{"参数相同无需升级", hashPasswordWith("secret", argon2Time), "secret", "", true, false},
+		{"参数不同需升级", hashPasswordWith("secret", 1), "secret", "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash := VerifyPassword(tt.encoded, tt.password, tt.legacySalt)
+			if match != tt.match || rehash != tt.rehash {
+				t.Errorf("VerifyPassword = (%v, %v)，期望 (%v, %v)", match, rehash, tt.match, tt.rehash)
+			}
+		})
+	}
+}
+
+// 使用指定迭代次数生成哈希，用于校验参数变化后需要重新哈希
+func hashPasswordWith(password string, time uint32) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, argon2Memory, time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
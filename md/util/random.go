@@ -2,6 +2,8 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
 	"strings"
 
@@ -31,6 +33,17 @@ func UuidNoHyphen() string {
 	return strings.ReplaceAll(result.String(), "-", "")
 }
 
+// 使用安全随机数生成指定字节数的十六进制字符串，用于不可猜测的令牌
+// 安全随机数不可用时返回错误，不能退回到可预测的随机数
+func RandomToken(byteLength int) (string, error) {
+	b := make([]byte, byteLength)
+	_, err := crand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // 随机生成字符串
 func RandomString(length int) string {
 	return random(length, letters)
@@ -115,6 +115,18 @@ func (s *SqlCompletion) NotIn(field string, params []interface{}, isAnd bool) *S
 	return s
 }
 
+// in 子查询，subSql 中使用 ? 作为参数占位符
+func (s *SqlCompletion) InSql(field string, subSql string, params []interface{}, isAnd bool) *SqlCompletion {
+	s.whereHyphen(isAnd)
+	s.whereSql.WriteString(field)
+	s.whereSql.WriteString(" in (")
+	s.whereSql.WriteString(NumberPlaceholders(subSql, s.paramIndex))
+	s.whereSql.WriteString(")")
+	s.paramIndex = s.paramIndex + len(params)
+	s.whereParams = append(s.whereParams, params...)
+	return s
+}
+
 // is null
 func (s *SqlCompletion) IsNull(field string, isAnd bool) *SqlCompletion {
 	s.whereHyphen(isAnd)
@@ -266,3 +278,21 @@ func (s *SqlCompletion) whereHyphen(isAnd bool) {
 		}
 	}
 }
+
+// NumberPlaceholders 函数将 sql 中的 ? 占位符替换为从 offset+1 开始编号的 $n 占位符
+func NumberPlaceholders(sql string, offset int) string {
+	var sb strings.Builder
+	for _, part := range strings.SplitAfter(sql, "?") {
+		if strings.HasSuffix(part, "?") {
+			offset++
+			part = strings.TrimSuffix(part, "?") + "$" + strconv.Itoa(offset)
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+// EscapeLike 函数转义 like 匹配中的通配符 % 和 _ 以及转义符 \，需配合 escape '\' 使用
+func EscapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
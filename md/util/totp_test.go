@@ -0,0 +1,92 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B的SHA1测试密钥 "12345678901234567890" 的base32编码
+const totpTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238 附录B的8位验证码，取后6位
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+	for _, tt := range tests {
+		code, err := TotpCode(totpTestSecret, TotpStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("时间 %d 的验证码为 %s，期望 %s", tt.unix, code, tt.code)
+		}
+	}
+
+	// 密钥不区分大小写
+	lower, err := TotpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil || lower != "287082" {
+		t.Errorf("小写密钥的验证码为 %s，期望 287082", lower)
+	}
+	if _, err := TotpCode("1", 1); err == nil {
+		t.Error("无效密钥应返回错误")
+	}
+}
+
+func TestTotpVerify(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TotpStep(now)
+	code := func(step int64) string {
+		c, err := TotpCode(totpTestSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		step     int64
+		ok       bool
+	}{
+		{"当前时间步", code(step), 0, step, true},
+		{"前一个时间步", code(step - 1), 0, step - 1, true},
+		{"后一个时间步", code(step + 1), 0, step + 1, true},
+		{"早两个时间步", code(step - 2), 0, 0, false},
+		{"晚两个时间步", code(step + 2), 0, 0, false},
+		{"重放已使用的时间步", code(step), step, 0, false},
+		{"早于已使用的时间步", code(step - 1), step, 0, false},
+		{"晚于已使用的时间步", code(step + 1), step, step + 1, true},
+		{"位数不正确", "05047", 0, 0, false},
+		{"8位验证码", "14050471", 0, 0, false},
+		{"空验证码", "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := TotpVerify(totpTestSecret, tt.code, now, tt.lastStep)
+			if ok != tt.ok || got != tt.step {
+				t.Errorf("TotpVerify = (%d, %v)，期望 (%d, %v)", got, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTotpSecret(t *testing.T) {
+	secret, err := TotpSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TotpCode(secret, 1); err != nil {
+		t.Errorf("生成的密钥无法使用: %v", err)
+	}
+}
@@ -7,6 +7,8 @@ interface Doc {
   bookId: string;
   createTime?: number;
   updateTime?: number;
+  version?: number; // 内容版本号，每次修改内容加1
+  expectedVersion?: number; // 保存内容时读取到的版本号，与服务端不一致时拒绝保存
 }
 
 interface CurrentDoc {
@@ -16,6 +18,7 @@ interface CurrentDoc {
   originMD5: string;
   type: string;
   updateTime: string;
+  version: number;
 }
 
 interface DocPageResult {